@@ -0,0 +1,133 @@
+package mgojq
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// RetryWhere will enqueue again all failed, cancelled and expired jobs that
+// match the specified filter. The attempts of the jobs are reset so that jobs
+// that have used all their attempts are processed again. The expiry time is
+// removed so that expired jobs do not expire again right away. It returns the
+// number of affected jobs.
+func (c *Collection) RetryWhere(filter Filter) (int, error) {
+	n, err := c.updateAll(filter.restrict(StatusFailed, StatusCancelled, StatusExpired), bson.M{
+		"$set": bson.M{
+			"status":   StatusEnqueued,
+			"delayed":  time.Now(),
+			"attempts": 0,
+		},
+		"$unset": bson.M{
+			"ended":   "",
+			"reason":  "",
+			"expires": "",
+		},
+	}, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// CancelWhere will cancel all enqueued and failed jobs that match the specified
+// filter using the specified reason. It returns the number of affected jobs.
+func (c *Collection) CancelWhere(filter Filter, reason string) (int, error) {
+	update := c.cancelJob(reason)
+	n, err := c.updateAll(filter.restrict(StatusEnqueued, StatusFailed), update, update["$set"].(bson.M)["ended"].(time.Time))
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// RescheduleWhere will delay all enqueued and failed jobs that match the
+// specified filter by the specified duration from now. It returns the number
+// of affected jobs.
+func (c *Collection) RescheduleWhere(filter Filter, delay time.Duration) (int, error) {
+	n, err := c.jobs.UpdateAll(filter.restrict(StatusEnqueued, StatusFailed), bson.M{
+		"$set": bson.M{
+			"delayed": time.Now().Add(delay),
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// DeleteWhere will delete all jobs that match the specified filter and are
+// not currently dequeued together with their logs. It returns the number of
+// affected jobs.
+func (c *Collection) DeleteWhere(filter Filter) (int, error) {
+	// find matching jobs
+	query := filter.restrict(StatusEnqueued, StatusFailed, StatusCompleted, StatusCancelled, StatusExpired)
+	var jobs []Job
+	err := c.jobs.Find(query, FindOptions{
+		Select: bson.M{
+			"_id": 1,
+		},
+	}, &jobs)
+	if err != nil || len(jobs) == 0 {
+		return 0, err
+	}
+
+	// collect ids
+	ids := make([]bson.ObjectId, 0, len(jobs))
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+
+	// remove jobs that still match
+	query["_id"] = bson.M{
+		"$in": ids,
+	}
+	n, err := c.jobs.RemoveAll(query)
+	if err != nil {
+		return 0, err
+	}
+
+	// remove logs
+	_, err = c.logs().RemoveAll(bson.M{
+		"job": bson.M{
+			"$in": ids,
+		},
+	})
+	if err != nil {
+		return n, err
+	}
+
+	// remove payloads
+	err = c.removePayloads(ids)
+	if err != nil {
+		return n, err
+	}
+
+	return n, nil
+}
+
+func (f Filter) restrict(allowed ...string) bson.M {
+	// intersect statuses if specified
+	statuses := allowed
+	if len(f.Statuses) > 0 {
+		statuses = []string{}
+		for _, status := range f.Statuses {
+			for _, a := range allowed {
+				if status == a {
+					statuses = append(statuses, status)
+				}
+			}
+		}
+	}
+
+	// prepare query
+	query := f.query()
+	query["status"] = bson.M{
+		"$in": statuses,
+	}
+
+	return query
+}
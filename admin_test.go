@@ -0,0 +1,164 @@
+package mgojq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionRetryWhere(t *testing.T) {
+	jqc := newCollection("test-coll-retry-where")
+
+	id1, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	id2, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	_, err = jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	err = jqc.Fail(id1, "some error", time.Hour)
+	assert.NoError(t, err)
+
+	err = jqc.Complete(id2, nil)
+	assert.NoError(t, err)
+
+	n, err := jqc.RetryWhere(Filter{
+		Names: []string{"foo"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	job, err := jqc.Fetch(id1)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusEnqueued, job.Status)
+	assert.True(t, job.Ended.IsZero())
+	assert.Equal(t, "some error", job.Error)
+
+	n, err = jqc.RetryWhere(Filter{
+		Statuses: []string{StatusCompleted},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestCollectionRetryWhereExhausted(t *testing.T) {
+	jqc := newCollection("test-coll-retry-where-exhausted")
+
+	id, err := jqc.EnqueueWith("foo", nil, Options{
+		MaxAttempts: 1,
+		Expires:     time.Now().Add(-time.Minute),
+	})
+	assert.NoError(t, err)
+
+	n, err := jqc.Sweep(time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	n, err = jqc.RetryWhere(Filter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id, job.ID)
+	assert.Equal(t, 1, job.Attempts)
+	assert.True(t, job.Expires.IsZero())
+
+	err = jqc.Fail(id, "some error", 0)
+	assert.NoError(t, err)
+
+	job, err = jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCancelled, job.Status)
+	assert.Equal(t, exhaustedReason, job.Reason)
+
+	n, err = jqc.RetryWhere(Filter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id, job.ID)
+	assert.Equal(t, 1, job.Attempts)
+}
+
+func TestCollectionCancelWhere(t *testing.T) {
+	jqc := newCollection("test-coll-cancel-where")
+
+	id1, err := jqc.Enqueue("foo", bson.M{"tenant": "x"}, 0)
+	assert.NoError(t, err)
+
+	_, err = jqc.Enqueue("foo", bson.M{"tenant": "y"}, 0)
+	assert.NoError(t, err)
+
+	id3, err := jqc.Enqueue("bar", bson.M{"tenant": "x"}, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"bar"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id3, job.ID)
+
+	n, err := jqc.CancelWhere(Filter{
+		Params: bson.M{"tenant": "x"},
+	}, "tenant removed")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	job, err = jqc.Fetch(id1)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCancelled, job.Status)
+	assert.Equal(t, "tenant removed", job.Reason)
+
+	job, err = jqc.Fetch(id3)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusDequeued, job.Status)
+}
+
+func TestCollectionRescheduleWhere(t *testing.T) {
+	jqc := newCollection("test-coll-reschedule-where")
+
+	_, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	n, err := jqc.RescheduleWhere(Filter{
+		Names: []string{"foo"},
+	}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+}
+
+func TestCollectionDeleteWhere(t *testing.T) {
+	jqc := newCollection("test-coll-delete-where")
+	dbc := jqc.jobs
+
+	_, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	_, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+
+	n, err := jqc.DeleteWhere(Filter{
+		Names: []string{"foo"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	count, err := count(dbc)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	_, err = jqc.Fetch(id)
+	assert.Equal(t, mgo.ErrNotFound, err)
+}
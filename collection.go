@@ -2,10 +2,15 @@
 package mgojq
 
 import (
+	"context"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // The available job statuses.
@@ -15,6 +20,7 @@ const (
 	StatusCompleted = "completed"
 	StatusFailed    = "failed"
 	StatusCancelled = "cancelled"
+	StatusExpired   = "expired"
 )
 
 // A Job as it is returned by Dequeue.
@@ -37,16 +43,45 @@ type Job struct {
 	// The time until the job is delayed for execution.
 	Delayed time.Time `bson:",omitempty"`
 
+	// The time after which the job will not be dequeued anymore.
+	Expires time.Time `bson:",omitempty"`
+
+	// The duration after which a dequeued job is considered abandoned and can
+	// be dequeued again. If zero, the timeout supplied to Dequeue is used.
+	Timeout time.Duration `bson:",omitempty"`
+
+	// The number of attempts after which a failed job is cancelled instead.
+	MaxAttempts int `bson:",omitempty"`
+
+	// The priority of the job. Jobs with a higher priority are dequeued first.
+	Priority int `bson:",omitempty"`
+
+	// The queue the job belongs to.
+	Queue string `bson:",omitempty"`
+
 	// The time when the job was the last time dequeued.
 	Started time.Time `bson:",omitempty"`
 
-	// The time when the job was ended (completed, failed or cancelled).
+	// The identity of the worker that currently owns the dequeued job.
+	Worker string `bson:",omitempty"`
+
+	// The time when the job was ended (completed, failed, cancelled or expired).
 	Ended time.Time `bson:",omitempty"`
 
 	// Attempts can be used to determine if a job should be cancelled after too
 	// many attempts.
 	Attempts int
 
+	// The recorded attempts, newest first. See SetHistory for details.
+	History []Attempt `bson:",omitempty"`
+
+	// The last progress reported by the worker.
+	Progress *Progress `bson:",omitempty"`
+
+	// The state persisted by the worker using Checkpoint. It is kept across
+	// attempts and allows retried jobs to resume where they stopped.
+	Checkpoint bson.M `bson:",omitempty"`
+
 	// The supplied result submitted during completion.
 	Result bson.M `bson:",omitempty"`
 
@@ -55,54 +90,444 @@ type Job struct {
 
 	// The reason that has been submitted when job was cancelled.
 	Reason string `bson:",omitempty"`
+
+	// The trace context of the enqueuer.
+	Trace map[string]string `bson:",omitempty"`
+
+	// The encrypted params, result and error. See SetEncryption for details.
+	Encrypted *Envelope `bson:",omitempty"`
+
+	// The compressed params and result. See SetCompression for details.
+	Compressed *Compressed `bson:",omitempty"`
+
+	// The references to offloaded params and result. See SetOffload for
+	// details.
+	Refs *Refs `bson:",omitempty"`
+
+	// Whether logs have been attached to the job. See Log for details.
+	Logged bool `bson:",omitempty"`
+
+	ctx       context.Context
+	reclaimed bool
+}
+
+// Context will return the context of the job. When the job is processed by a
+// pool, the context carries the span of the processing.
+func (j *Job) Context() context.Context {
+	// check context
+	if j.ctx == nil {
+		return context.Background()
+	}
+
+	return j.ctx
+}
+
+// Progress describes the progress reported by a worker.
+type Progress struct {
+	// The completed percentage of the job.
+	Percent float64
+
+	// An optional message describing the current step.
+	Message string
+
+	// The time when the progress has been reported.
+	Updated time.Time
+}
+
+// Options can be used to further configure enqueued jobs. Zero values are
+// replaced by the defaults from the job definition.
+type Options struct {
+	// The delay after which the job can be dequeued.
+	Delay time.Duration
+
+	// The time after which the job will not be dequeued anymore. Jobs that have
+	// not been completed in time are marked as expired by Sweep.
+	Expires time.Time
+
+	// The duration after which the job is considered abandoned once dequeued.
+	// It overrides the timeout supplied to Dequeue.
+	Timeout time.Duration
+
+	// The number of attempts after which a failed job is cancelled instead.
+	MaxAttempts int
+
+	// The priority of the job. Jobs with a higher priority are dequeued first.
+	// Priorities must not be negative.
+	Priority int
+
+	// The queue the job belongs to.
+	Queue string
+
+	// The context whose trace is propagated to the job.
+	Context context.Context
 }
 
 // A Bulk represents an operation that can be used to enqueue multiple jobs at
-// once. It is a wrapper around the mgo.Bulk type.
+// once.
 type Bulk struct {
-	coll *Collection
-	bulk *mgo.Bulk
+	coll    *Collection
+	jobs    []*Job
+	updates []bulkUpdate
+	events  []Event
+	err     error
+}
+
+type bulkUpdate struct {
+	id     bson.ObjectId
+	update bson.M
+	fail   *bulkFail
+	event  int
+}
+
+type bulkFail struct {
+	error string
+	delay time.Duration
 }
 
 // Enqueue will queue the insert in the bulk operation. The returned id is only
 // valid if the bulk operation run successfully,.
 func (b *Bulk) Enqueue(name string, params bson.M, delay time.Duration) bson.ObjectId {
-	id, doc := b.coll.insertJob(name, params, delay)
-	b.bulk.Insert(doc)
+	return b.EnqueueWith(name, params, Options{Delay: delay})
+}
+
+// EnqueueWith will queue the insert in the bulk operation using the specified
+// options. The returned id is only valid if the bulk operation run
+// successfully. If the params are invalid, the bulk operation will not run and
+// return the validation error.
+func (b *Bulk) EnqueueWith(name string, params bson.M, opts Options) bson.ObjectId {
+	// validate params
+	err := b.coll.validate(name, params)
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+
+	// prepare job
+	id, doc, err := b.coll.insertJob(name, params, opts)
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+
+	if doc != nil {
+		b.jobs = append(b.jobs, doc)
+	}
+	b.events = append(b.events, Event{Type: EventEnqueued, Job: id, Name: name})
 	return id
 }
 
 // Complete will queue the complete in the bulk operation.
 func (b *Bulk) Complete(id bson.ObjectId, result bson.M) {
-	b.bulk.Update(bson.M{"_id": id}, b.coll.completeJob(result))
+	update, err := b.coll.completeJob(id, result)
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+
+	b.updates = append(b.updates, bulkUpdate{id: id, update: update})
+	b.events = append(b.events, Event{Type: EventCompleted, Job: id})
 }
 
-// Fail will queue the fail in the bulk operation.
+// Fail will queue the fail in the bulk operation. Like with Collection.Fail,
+// the backoff and the maximum number of attempts of the job definition apply.
+// They are determined for all failed jobs at once when the bulk operation is
+// run.
 func (b *Bulk) Fail(id bson.ObjectId, error string, delay time.Duration) {
-	b.bulk.Update(bson.M{"_id": id}, b.coll.failJob(error, delay))
+	b.updates = append(b.updates, bulkUpdate{id: id, fail: &bulkFail{error: error, delay: delay}, event: len(b.events)})
+	b.events = append(b.events, Event{Type: EventFailed, Job: id})
 }
 
 // Cancel will queue the cancel in the bulk operation.
 func (b *Bulk) Cancel(id bson.ObjectId, reason string) {
-	b.bulk.Update(bson.M{"_id": id}, b.coll.cancelJob(reason))
+	b.updates = append(b.updates, bulkUpdate{id: id, update: b.coll.cancelJob(reason)})
+	b.events = append(b.events, Event{Type: EventCancelled, Job: id})
+}
+
+func (b *Bulk) operations() ([]Operation, error) {
+	// prepare failed jobs
+	err := b.prepareFails()
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare operations
+	var ops []Operation
+
+	// offload params of inserted jobs
+	for _, job := range b.jobs {
+		err = b.coll.offloadParams(job)
+		if err != nil {
+			return nil, err
+		}
+
+		ops = append(ops, Operation{Insert: job})
+	}
+
+	// offload results of updated jobs
+	for _, u := range b.updates {
+		err = b.coll.offloadResult(u.id, u.update)
+		if err != nil {
+			return nil, err
+		}
+
+		ops = append(ops, b.coll.finishOperations(u.id, u.update)...)
+	}
+
+	return ops, nil
+}
+
+func (b *Bulk) prepareFails() error {
+	// collect failed jobs
+	var ids []bson.ObjectId
+	for _, u := range b.updates {
+		if u.fail != nil {
+			ids = append(ids, u.id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// load attempts of failed jobs
+	var list []Job
+	err := b.coll.jobs.Find(bson.M{
+		"_id": bson.M{
+			"$in": ids,
+		},
+	}, FindOptions{
+		Select: bson.M{
+			"name":        1,
+			"attempts":    1,
+			"maxattempts": 1,
+		},
+	}, &list)
+	if err != nil {
+		return err
+	}
+	jobs := map[bson.ObjectId]Job{}
+	for _, job := range list {
+		jobs[job.ID] = job
+	}
+
+	// prepare updates, unknown jobs are failed without a match
+	for i, u := range b.updates {
+		if u.fail == nil {
+			continue
+		}
+
+		job, ok := jobs[u.id]
+		if !ok {
+			job = Job{ID: u.id}
+		}
+
+		event, update, err := b.coll.failAttempt(job, u.fail.error, u.fail.delay)
+		if err != nil {
+			return err
+		}
+
+		b.updates[i].update = update
+		b.events[u.event].Type = event
+	}
+
+	return nil
+}
+
+func (b *Bulk) endRelated() error {
+	// check updates
+	if len(b.updates) == 0 {
+		return nil
+	}
+
+	// collect updated jobs
+	ids := make([]bson.ObjectId, 0, len(b.updates))
+	updates := make([]bson.M, 0, len(b.updates))
+	for _, u := range b.updates {
+		ids = append(ids, u.id)
+		updates = append(updates, u.update)
+	}
+
+	// find updated jobs with logs or payloads
+	var list []Job
+	err := b.coll.jobs.Find(bson.M{
+		"_id": bson.M{
+			"$in": ids,
+		},
+		"$or": []bson.M{
+			{"logged": true},
+			{"refs": bson.M{"$exists": true}},
+		},
+	}, FindOptions{
+		Select: bson.M{
+			"logged": 1,
+			"refs":   1,
+		},
+	}, &list)
+	if err != nil {
+		return err
+	}
+	found := map[bson.ObjectId]Job{}
+	for _, job := range list {
+		found[job.ID] = job
+	}
+
+	// prepare jobs
+	jobs := make([]Job, 0, len(ids))
+	for _, id := range ids {
+		job, ok := found[id]
+		if !ok {
+			job = Job{ID: id}
+		}
+		jobs = append(jobs, job)
+	}
+
+	return b.coll.endRelated(jobs, updates, time.Now())
 }
 
-// Run will insert all queued insert operations.
+func (c *Collection) finishOperations(id bson.ObjectId, update bson.M) []Operation {
+	// update dequeued job with attempt or other job, the updates are
+	// idempotent and may run in any order
+	return []Operation{{
+		Query: bson.M{
+			"_id":    id,
+			"status": StatusDequeued,
+		},
+		Update: c.endAttempt(update),
+	}, {
+		Query: bson.M{
+			"_id": id,
+			"status": bson.M{
+				"$ne": StatusDequeued,
+			},
+		},
+		Update: update,
+	}}
+}
+
+// Run will insert all queued insert operations. Observers of the collection
+// are notified about all operations once the bulk operation succeeded.
 func (b *Bulk) Run() error {
-	_, err := b.bulk.Run()
-	return err
+	// check error
+	if b.err != nil {
+		return b.err
+	}
+
+	// prepare and run operations
+	ops, err := b.operations()
+	if err == nil {
+		err = b.coll.jobs.Bulk(ops)
+	}
+	if err != nil {
+		// remove payloads that are not referenced by a job
+		ids := make([]bson.ObjectId, 0, len(b.jobs)+len(b.updates))
+		for _, job := range b.jobs {
+			ids = append(ids, job.ID)
+		}
+		for _, u := range b.updates {
+			ids = append(ids, u.id)
+		}
+		_ = b.coll.removePayloads(ids)
+
+		return err
+	}
+
+	// collect updated jobs
+	var ids []bson.ObjectId
+	for _, event := range b.events {
+		if event.Type != EventEnqueued {
+			ids = append(ids, event.Job)
+		}
+	}
+
+	// update logs and payloads
+	err = b.endRelated()
+	if err != nil {
+		return err
+	}
+
+	// check observers
+	if len(b.coll.observers) == 0 {
+		return nil
+	}
+
+	// load updated jobs
+	jobs := map[bson.ObjectId]Job{}
+	if len(ids) > 0 {
+		var list []Job
+		err = b.coll.jobs.Find(bson.M{
+			"_id": bson.M{
+				"$in": ids,
+			},
+		}, FindOptions{
+			Select: bson.M{
+				"name":     1,
+				"started":  1,
+				"attempts": 1,
+			},
+		}, &list)
+		if err != nil {
+			return err
+		}
+		for _, job := range list {
+			jobs[job.ID] = job
+		}
+	}
+
+	// notify observers
+	for _, event := range b.events {
+		if job, ok := jobs[event.Job]; ok {
+			event.Name = job.Name
+			event.Attempt = job.Attempts
+			if !job.Started.IsZero() {
+				event.Duration = time.Since(job.Started)
+			}
+		}
+		b.coll.notify(event)
+	}
+
+	return nil
 }
 
-// A Collection represents a job queue enabled collection. It is a wrapper
-// around the mgo.Collection type.
+// A Collection represents a job queue enabled collection. It stores its jobs
+// and companion documents in the tables of a Store.
 type Collection struct {
-	coll *mgo.Collection
+	store     Store
+	jobs      Table
+	coll      *mgo.Collection
+	limits    map[string]Limit
+	history   int
+	observers []Observer
+	logger    *slog.Logger
+	contexts  sync.Map
+	beats     sync.Map
+	keys      KeyProvider
+	threshold int
+	codec     string
+	minSize   int
+
+	definitions map[string]JobDefinition
 }
 
 // Wrap will take a mgo.Collection and return a Collection.
 func Wrap(coll *mgo.Collection) *Collection {
+	return New(NewMongoStore(coll))
+}
+
+// New will return a Collection that uses the specified store. Features that
+// depend on MongoDB e.g. indexes, validators, statistics and offloading are
+// only available with a MongoStore.
+func New(store Store) *Collection {
+	// get mongo collection
+	var coll *mgo.Collection
+	if ms, ok := store.(*MongoStore); ok {
+		coll = ms.Collection()
+	}
+
 	return &Collection{
-		coll: coll,
+		store:   store,
+		jobs:    store.Table(""),
+		coll:    coll,
+		limits:  make(map[string]Limit),
+		history: defaultHistory,
+		logger:  discard,
+
+		definitions: make(map[string]JobDefinition),
 	}
 }
 
@@ -110,44 +535,249 @@ func Wrap(coll *mgo.Collection) *Collection {
 // is specified the job will not dequeued until the specified time has passed.
 // If not error is returned the returned job id is valid.
 func (c *Collection) Enqueue(name string, params bson.M, delay time.Duration) (bson.ObjectId, error) {
-	id, doc := c.insertJob(name, params, delay)
-	return id, c.coll.Insert(doc)
+	return c.EnqueueWith(name, params, Options{Delay: delay})
+}
+
+// EnqueueWith will enqueue a job using the specified name, params and options.
+// If not error is returned the returned job id is valid. If a context is
+// specified, its trace is propagated to the job. Params that are rejected by
+// the job definition yield a *ValidationError.
+func (c *Collection) EnqueueWith(name string, params bson.M, opts Options) (bson.ObjectId, error) {
+	// validate params
+	err := c.validate(name, params)
+	if err != nil {
+		return "", err
+	}
+
+	// get context
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// start span
+	ctx, span := tracer().Start(ctx, "mgojq.enqueue", trace.WithSpanKind(trace.SpanKindProducer))
+	opts.Context = ctx
+
+	// prepare job
+	id, doc, err := c.insertJob(name, params, opts)
+	if err != nil {
+		endSpan(span, err)
+		return "", err
+	}
+
+	// offload params
+	err = c.offloadParams(doc)
+	if err != nil {
+		endSpan(span, err)
+		return "", err
+	}
+
+	// insert job
+	span.SetAttributes(jobAttributes(doc)...)
+	err = c.jobs.Insert(doc)
+	endSpan(span, err)
+	if err != nil {
+		// remove offloaded params
+		_ = c.removePayloads([]bson.ObjectId{id})
+		return id, err
+	}
+
+	// notify observers
+	c.notify(Event{
+		Type: EventEnqueued,
+		Job:  id,
+		Name: name,
+	})
+
+	return id, nil
 }
 
-func (c *Collection) insertJob(name string, params bson.M, delay time.Duration) (bson.ObjectId, *Job) {
+func (c *Collection) insertJob(name string, params bson.M, opts Options) (bson.ObjectId, *Job, error) {
+	// check priority
+	if opts.Priority < 0 {
+		panic("priority must not be negative")
+	}
+
 	id := bson.NewObjectId()
 
-	return id, &Job{
+	// apply defaults
+	opts = c.defaults(name, opts)
+
+	// encrypt or compress params
+	var env *Envelope
+	var cmp *Compressed
+	if c.keys != nil && params != nil {
+		ct, err := c.sealDoc(id, "params", params)
+		if err != nil {
+			return id, nil, err
+		}
+		env = &Envelope{Params: ct}
+		params = nil
+	} else if c.codec != "" && params != nil {
+		blob, err := c.compressDoc(params)
+		if err != nil {
+			return id, nil, err
+		}
+		if blob != nil {
+			cmp = &Compressed{Params: blob}
+			params = nil
+		}
+	}
+
+	// prepare job
+	job := &Job{
 		ID:      id,
 		Name:    name,
 		Params:  params,
 		Status:  StatusEnqueued,
 		Created: time.Now(),
-		Delayed: time.Now().Add(delay),
+		Delayed: time.Now().Add(opts.Delay),
+		Expires: opts.Expires,
+		Timeout: opts.Timeout,
+		Trace:   inject(opts.Context),
+
+		MaxAttempts: opts.MaxAttempts,
+		Priority:    opts.Priority,
+		Queue:       opts.Queue,
+		Encrypted:   env,
+		Compressed:  cmp,
 	}
+
+	return id, job, nil
 }
 
 // Bulk will return a new bulk operation.
 func (c *Collection) Bulk() *Bulk {
-	// create new bulk operation
-	bulk := c.coll.Bulk()
-	bulk.Unordered()
-
-	return &Bulk{coll: c, bulk: bulk}
+	return &Bulk{coll: c}
 }
 
-// Dequeue will try to dequeue a job.
+// Dequeue will try to dequeue a job. Jobs with paused names and expired jobs
+// are skipped. Dequeued jobs are dequeued again once their own timeout or the
+// specified timeout has passed since they have been started or reported their
+// last progress. Jobs whose params cannot be decrypted, loaded or decompressed
+// are cancelled with the error and skipped.
 func (c *Collection) Dequeue(names []string, timeout time.Duration) (*Job, error) {
+	return c.DequeueAs("", names, timeout)
+}
+
+// DequeueAs will try to dequeue a job like Dequeue and record the specified
+// worker identity as the owner of the job. The owner is cleared when the job
+// is completed, failed or cancelled.
+func (c *Collection) DequeueAs(worker string, names []string, timeout time.Duration) (*Job, error) {
+	return c.DequeueFrom(worker, nil, names, timeout)
+}
+
+// DequeueFrom will try to dequeue a job like DequeueAs but only considers jobs
+// that belong to one of the specified queues. Jobs from all queues are
+// considered if no queues are specified.
+func (c *Collection) DequeueFrom(worker string, queues, names []string, timeout time.Duration) (*Job, error) {
 	// check names
 	if len(names) == 0 {
 		panic("at least one job name is required")
 	}
 
-	var job Job
-	_, err := c.coll.Find(bson.M{
+	// get paused names
+	paused, err := c.Paused()
+	if err != nil {
+		return nil, err
+	}
+
+	// remove paused names
+	names = without(names, paused)
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	return c.dequeue(worker, names, queues, timeout)
+}
+
+func (c *Collection) dequeue(worker string, names, queues []string, timeout time.Duration) (*Job, error) {
+	// get time
+	start := time.Now()
+
+	// start span
+	_, span := tracer().Start(context.Background(), "mgojq.dequeue",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("mgojq.worker", worker)),
+	)
+
+	// use limited dequeue if some names are limited
+	var job *Job
+	var err error
+	if c.limited(names) {
+		job, err = c.dequeueLimited(worker, names, queues, timeout)
+	} else {
+		for {
+			job, err = c.claim(worker, c.available(names, queues, timeout))
+			if err != errUnreadable {
+				break
+			}
+		}
+	}
+	if err != nil || job == nil {
+		endSpan(span, err)
+		return nil, err
+	}
+
+	// apply definition timeout to jobs without a timeout
+	if def, ok := c.definitions[job.Name]; ok && job.Timeout == 0 && def.Timeout > 0 {
+		err = c.jobs.Update(bson.M{
+			"_id":     job.ID,
+			"status":  StatusDequeued,
+			"timeout": nil,
+		}, bson.M{
+			"$set": bson.M{
+				"timeout": def.Timeout,
+			},
+		})
+		if err != nil && err != mgo.ErrNotFound {
+			endSpan(span, err)
+			return nil, err
+		}
+		job.Timeout = def.Timeout
+	}
+
+	// end span
+	for _, link := range links(job.Trace) {
+		span.AddLink(link)
+	}
+	span.SetAttributes(jobAttributes(job)...)
+	endSpan(span, nil)
+
+	// notify observers about abandoned job
+	if job.reclaimed {
+		c.notify(Event{
+			Type:    EventReclaimed,
+			Job:     job.ID,
+			Name:    job.Name,
+			Attempt: job.Attempts - 1,
+		})
+	}
+
+	// notify observers
+	c.notify(Event{
+		Type:     EventDequeued,
+		Job:      job.ID,
+		Name:     job.Name,
+		Attempt:  job.Attempts,
+		Duration: time.Since(start),
+	})
+
+	return job, nil
+}
+
+func (c *Collection) available(names, queues []string, timeout time.Duration) bson.M {
+	// prepare query
+	query := bson.M{
 		"name": bson.M{
 			"$in": names,
 		},
+		"expires": bson.M{
+			"$not": bson.M{
+				"$lte": time.Now(),
+			},
+		},
 		"$or": []bson.M{
 			{
 				"status": bson.M{
@@ -159,73 +789,263 @@ func (c *Collection) Dequeue(names []string, timeout time.Duration) (*Job, error
 			},
 			{
 				"status": StatusDequeued,
-				"started": bson.M{
-					"$lte": time.Now().Add(-timeout),
-				},
+				"$expr":  abandoned(timeout),
 			},
 		},
-	}).Sort("_id").Apply(mgo.Change{
-		Update: bson.M{
-			"$set": bson.M{
-				"status":  StatusDequeued,
-				"started": time.Now(),
-			},
-			"$inc": bson.M{
-				"attempts": 1,
+	}
+
+	// restrict queues
+	if len(queues) > 0 {
+		query["queue"] = bson.M{
+			"$in": queues,
+		}
+	}
+
+	return query
+}
+
+func abandoned(timeout time.Duration) bson.M {
+	return bson.M{
+		"$lte": []interface{}{
+			bson.M{
+				"$add": []interface{}{
+					bson.M{"$max": []interface{}{"$started", "$progress.updated"}},
+					bson.M{"$ifNull": []interface{}{"$timeout", int64(timeout / time.Millisecond)}},
+				},
 			},
+			time.Now(),
+		},
+	}
+}
+
+func (c *Collection) claim(worker string, query bson.M) (*Job, error) {
+	// get time
+	now := time.Now()
+
+	// prepare update
+	update := bson.M{
+		"$set": bson.M{
+			"status":  StatusDequeued,
+			"started": now,
 		},
-		ReturnNew: true,
-	}, &job)
+		"$inc": bson.M{
+			"attempts": 1,
+		},
+		"$unset": bson.M{
+			"progress": "",
+		},
+	}
+
+	// set or clear worker
+	if worker != "" {
+		update["$set"].(bson.M)["worker"] = worker
+	} else {
+		update["$unset"].(bson.M)["worker"] = ""
+	}
+
+	// record attempt
+	c.startAttempt(update, now, worker)
+
+	// update job and get previous state
+	var job Job
+	err := c.jobs.Modify(query, FindOptions{
+		Sort: []string{"-priority", "_id"},
+	}, update, &job)
 	if err == mgo.ErrNotFound {
 		return nil, nil
 	} else if err != nil {
 		return nil, err
 	}
 
+	// decrypt job and cancel it if that fails
+	err = c.open(&job)
+	if err != nil {
+		err = c.cancelUnreadable(job.ID, err)
+		if err != nil {
+			return nil, err
+		}
+		return nil, errUnreadable
+	}
+
+	// check if the job has been abandoned
+	job.reclaimed = job.Status == StatusDequeued
+
+	// apply update
+	job.Status = StatusDequeued
+	job.Started = now
+	job.Attempts++
+	job.Worker = worker
+	job.Progress = nil
+	if c.history > 0 {
+		job.History = append([]Attempt{{
+			Started: now,
+			Status:  StatusDequeued,
+			Worker:  worker,
+		}}, job.History...)
+		if len(job.History) > c.history {
+			job.History = job.History[:c.history]
+		}
+	}
+
 	return &job, nil
 }
 
+func (c *Collection) cancelUnreadable(id bson.ObjectId, cause error) error {
+	// log error
+	c.logger.Error("cancelling unreadable job",
+		slog.String("job", id.Hex()),
+		slog.String("error", cause.Error()),
+	)
+
+	// prepare update
+	update := c.cancelJob(unreadableReason)
+	update["$set"].(bson.M)["error"] = cause.Error()
+	update, err := c.sealError(id, update)
+	if err != nil {
+		return err
+	}
+
+	return c.finish(id, EventCancelled, update)
+}
+
 // Fetch will load the job with the specified id.
 func (c *Collection) Fetch(id bson.ObjectId) (*Job, error) {
+	// find job
 	var job Job
-	return &job, c.coll.FindId(id).One(&job)
+	err := c.jobs.FindOne(bson.M{"_id": id}, FindOptions{}, &job)
+	if err != nil {
+		return &job, err
+	}
+
+	// decrypt job
+	err = c.open(&job)
+	if err != nil {
+		return &job, err
+	}
+
+	return &job, nil
+}
+
+// Progress will report the progress of the specified job. The report also
+// acts as a heartbeat and prevents the job from being dequeued again before
+// its timeout has passed. If the job is processed by a pool of this collection,
+// the deadline of the worker is extended as well.
+func (c *Collection) Progress(id bson.ObjectId, percent float64, message string) error {
+	// update job
+	err := c.jobs.Update(bson.M{"_id": id}, bson.M{
+		"$set": bson.M{
+			"progress": &Progress{
+				Percent: percent,
+				Message: message,
+				Updated: time.Now(),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// signal heartbeat
+	if beats, ok := c.beats.Load(id); ok {
+		select {
+		case beats.(chan struct{}) <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Checkpoint will persist the specified state on the job. The state is kept
+// when the job fails and is returned with the job on the next Dequeue.
+func (c *Collection) Checkpoint(id bson.ObjectId, state bson.M) error {
+	return c.jobs.Update(bson.M{"_id": id}, bson.M{
+		"$set": bson.M{
+			"checkpoint": state,
+		},
+	})
 }
 
 // Complete will complete the specified job and set the specified result.
 func (c *Collection) Complete(id bson.ObjectId, result bson.M) error {
-	return c.coll.UpdateId(id, c.completeJob(result))
+	// prepare update
+	update, err := c.completeJob(id, result)
+	if err != nil {
+		return err
+	}
+
+	// offload result
+	err = c.offloadResult(id, update)
+	if err != nil {
+		return err
+	}
+
+	return c.finish(id, EventCompleted, update)
 }
 
-func (c *Collection) completeJob(result bson.M) bson.M {
-	return bson.M{
+func (c *Collection) completeJob(id bson.ObjectId, result bson.M) (bson.M, error) {
+	// prepare update
+	update := bson.M{
 		"$set": bson.M{
 			"status": StatusCompleted,
 			"result": result,
 			"ended":  time.Now(),
 		},
+		"$unset": bson.M{
+			"worker": "",
+		},
+	}
+
+	// encrypt or compress result
+	if c.keys != nil && result != nil {
+		ct, err := c.sealDoc(id, "result", result)
+		if err != nil {
+			return nil, err
+		}
+		delete(update["$set"].(bson.M), "result")
+		update["$set"].(bson.M)["encrypted.result"] = ct
+		update["$unset"].(bson.M)["result"] = ""
+	} else {
+		err := c.compressResult(update)
+		if err != nil {
+			return nil, err
+		}
 	}
+
+	return update, nil
 }
 
 // Fail will fail the specified job with the specified error. Delay can be set
-// enforce a delay until the job can be dequeued again.
+// enforce a delay until the job can be dequeued again. If no delay is set, the
+// backoff of the job definition is used. Jobs that have reached their maximum
+// number of attempts are cancelled instead.
 func (c *Collection) Fail(id bson.ObjectId, error string, delay time.Duration) error {
-	return c.coll.UpdateId(id, c.failJob(error, delay))
+	// prepare update
+	event, update, err := c.prepareFail(id, error, delay)
+	if err != nil {
+		return err
+	}
+
+	return c.finish(id, event, update)
 }
 
-func (c *Collection) failJob(error string, delay time.Duration) bson.M {
-	return bson.M{
+func (c *Collection) failJob(id bson.ObjectId, error string, delay time.Duration) (bson.M, error) {
+	return c.sealError(id, bson.M{
 		"$set": bson.M{
 			"status":  StatusFailed,
 			"error":   error,
 			"ended":   time.Now(),
 			"delayed": time.Now().Add(delay),
 		},
-	}
+		"$unset": bson.M{
+			"worker": "",
+		},
+	})
 }
 
 // Cancel will cancel the specified job with the specified reason.
 func (c *Collection) Cancel(id bson.ObjectId, reason string) error {
-	return c.coll.UpdateId(id, c.cancelJob(reason))
+	return c.finish(id, EventCancelled, c.cancelJob(reason))
 }
 
 func (c *Collection) cancelJob(reason string) bson.M {
@@ -235,17 +1055,165 @@ func (c *Collection) cancelJob(reason string) bson.M {
 			"reason": reason,
 			"ended":  time.Now(),
 		},
+		"$unset": bson.M{
+			"worker": "",
+		},
 	}
 }
 
+// Owned will return all dequeued jobs that are currently owned by the specified
+// worker.
+func (c *Collection) Owned(worker string) ([]Job, error) {
+	// find jobs
+	var jobs []Job
+	err := c.jobs.Find(bson.M{
+		"status": StatusDequeued,
+		"worker": worker,
+	}, FindOptions{
+		Sort: []string{"_id"},
+	}, &jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	// decrypt jobs
+	err = c.openAll(jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (c *Collection) finish(id bson.ObjectId, event string, update bson.M) error {
+	// start span
+	_, span := tracer().Start(c.tracked(id), "mgojq."+event, trace.WithAttributes(
+		attribute.String("mgojq.job.id", id.Hex()),
+	))
+
+	// prepare options
+	opts := FindOptions{
+		Select: bson.M{
+			"name":     1,
+			"started":  1,
+			"attempts": 1,
+			"logged":   1,
+			"refs":     1,
+		},
+	}
+
+	// update dequeued job with attempt or other job and get previous state
+	var job Job
+	err := c.jobs.Modify(bson.M{
+		"_id":    id,
+		"status": StatusDequeued,
+	}, opts, c.endAttempt(update), &job)
+	if err == mgo.ErrNotFound {
+		err = c.jobs.Modify(bson.M{"_id": id}, opts, update, &job)
+	}
+	endSpan(span, err)
+	if err != nil {
+		return err
+	}
+
+	// update logs and payloads
+	job.ID = id
+	err = c.endRelated([]Job{job}, []bson.M{update}, update["$set"].(bson.M)["ended"].(time.Time))
+	if err != nil {
+		return err
+	}
+
+	// get runtime
+	var runtime time.Duration
+	if !job.Started.IsZero() {
+		runtime = time.Since(job.Started)
+	}
+
+	// notify observers
+	c.notify(Event{
+		Type:     event,
+		Job:      id,
+		Name:     job.Name,
+		Attempt:  job.Attempts,
+		Duration: runtime,
+	})
+
+	return nil
+}
+
+// Sweep will mark all pending jobs that have passed their expiry time as
+// expired. Dequeued jobs that have passed their expiry time are marked as
+// expired once they have been abandoned, which is the case when their own
+// timeout or the specified timeout has passed since they have been started or
+// reported their last progress. It returns the number of expired jobs.
+func (c *Collection) Sweep(timeout time.Duration) (int, error) {
+	// get time
+	now := time.Now()
+
+	// expire pending jobs
+	n, err := c.updateAll(bson.M{
+		"status": bson.M{
+			"$in": []string{StatusEnqueued, StatusFailed},
+		},
+		"expires": bson.M{
+			"$lte": now,
+		},
+	}, bson.M{
+		"$set": bson.M{
+			"status": StatusExpired,
+			"ended":  now,
+		},
+	}, now)
+	if err != nil {
+		return 0, err
+	}
+
+	// expire abandoned jobs
+	m, err := c.updateAll(bson.M{
+		"status": StatusDequeued,
+		"expires": bson.M{
+			"$lte": now,
+		},
+		"$expr": abandoned(timeout),
+	}, c.endAttempt(bson.M{
+		"$set": bson.M{
+			"status": StatusExpired,
+			"ended":  now,
+		},
+		"$unset": bson.M{
+			"worker": "",
+		},
+	}), now)
+	if err != nil {
+		return n, err
+	}
+
+	// add expired jobs
+	n += m
+
+	// log expired jobs
+	if n > 0 {
+		c.logger.Info("expired jobs", slog.Int("count", n))
+	}
+
+	return n, nil
+}
+
 // EnsureIndexes will ensure that the necessary indexes have been created. If
 // removeAfter is specified, jobs are automatically removed when their ended
 // timestamp falls behind the specified duration. Warning: this also applies
-// to failed jobs!
+// to failed jobs! Job logs are removed together with their job. The indexes
+// of the payloads bucket are only created if offloading has been enabled.
 //
 // Note: It is recommended to create custom indexes that support the exact
-// nature of data and access patterns.
+// nature of data and access patterns. Indexes are only created with a
+// MongoStore, other stores are left unchanged.
 func (c *Collection) EnsureIndexes(removeAfter time.Duration) error {
+	// check store
+	if c.coll == nil {
+		return nil
+	}
+
 	// ensure name index
 	err := c.coll.EnsureIndex(mgo.Index{
 		Key:        []string{"name"},
@@ -264,6 +1232,35 @@ func (c *Collection) EnsureIndexes(removeAfter time.Duration) error {
 		return err
 	}
 
+	// ensure priority index
+	err = c.coll.EnsureIndex(mgo.Index{
+		Key:        []string{"-priority", "_id"},
+		Background: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	// ensure expires index
+	err = c.coll.EnsureIndex(mgo.Index{
+		Key:        []string{"expires"},
+		Sparse:     true,
+		Background: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	// ensure worker index
+	err = c.coll.EnsureIndex(mgo.Index{
+		Key:        []string{"worker"},
+		Sparse:     true,
+		Background: true,
+	})
+	if err != nil {
+		return err
+	}
+
 	// ensure ended index
 	err = c.coll.EnsureIndex(mgo.Index{
 		Key:         []string{"ended"},
@@ -274,5 +1271,45 @@ func (c *Collection) EnsureIndexes(removeAfter time.Duration) error {
 		return err
 	}
 
+	// ensure payload indexes if offloading is enabled
+	if c.threshold > 0 {
+		err = c.payloads().Files.EnsureIndex(mgo.Index{
+			Key:        []string{"metadata.job"},
+			Background: true,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = c.payloads().Files.EnsureIndex(mgo.Index{
+			Key:        []string{"metadata.ended"},
+			Sparse:     true,
+			Background: true,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// ensure log job index
+	logs := c.coll.Database.C(c.coll.Name + ".logs")
+	err = logs.EnsureIndex(mgo.Index{
+		Key:        []string{"job", "_id"},
+		Background: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	// ensure log ended index
+	err = logs.EnsureIndex(mgo.Index{
+		Key:         []string{"ended"},
+		ExpireAfter: removeAfter,
+		Background:  true,
+	})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
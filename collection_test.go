@@ -9,14 +9,14 @@ import (
 )
 
 func TestCollectionEnqueue(t *testing.T) {
-	dbc := db.C("test-coll-enqueue")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-coll-enqueue")
+	dbc := jqc.jobs
 
 	_, err := jqc.Enqueue("foo", bson.M{"bar": "baz"}, 0)
 	assert.NoError(t, err)
 
 	var data []bson.M
-	err = dbc.Find(nil).Select(bson.M{"_id": 0}).All(&data)
+	err = dbc.Find(nil, FindOptions{Select: bson.M{"_id": 0}}, &data)
 	assert.NoError(t, err)
 
 	assert.Equal(t, []bson.M{
@@ -33,9 +33,38 @@ func TestCollectionEnqueue(t *testing.T) {
 	}, replaceTimeSlice(data))
 }
 
+func TestCollectionEnqueueWith(t *testing.T) {
+	jqc := newCollection("test-coll-enqueue-with")
+	dbc := jqc.jobs
+
+	_, err := jqc.EnqueueWith("foo", bson.M{"bar": "baz"}, Options{
+		Delay:   time.Minute,
+		Expires: time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+
+	var data []bson.M
+	err = dbc.Find(nil, FindOptions{Select: bson.M{"_id": 0}}, &data)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []bson.M{
+		{
+			"name": "foo",
+			"params": bson.M{
+				"bar": "baz",
+			},
+			"status":   "enqueued",
+			"attempts": 0,
+			"created":  setTime,
+			"delayed":  setTime,
+			"expires":  setTime,
+		},
+	}, replaceTimeSlice(data))
+}
+
 func TestCollectionBulk(t *testing.T) {
-	dbc := db.C("test-coll-bulk")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-coll-bulk")
+	dbc := jqc.jobs
 
 	bulk := jqc.Bulk()
 	bulk.Enqueue("foo1", bson.M{"bar": 1}, 0)
@@ -46,7 +75,7 @@ func TestCollectionBulk(t *testing.T) {
 	assert.NoError(t, err)
 
 	var ids []bson.ObjectId
-	err = dbc.Find(nil).Distinct("_id", &ids)
+	err = dbc.Distinct(nil, "_id", &ids)
 	assert.NoError(t, err)
 	assert.Len(t, ids, 3)
 
@@ -59,7 +88,7 @@ func TestCollectionBulk(t *testing.T) {
 	assert.NoError(t, err)
 
 	var data []bson.M
-	err = dbc.Find(nil).Select(bson.M{"_id": 0}).All(&data)
+	err = dbc.Find(nil, FindOptions{Select: bson.M{"_id": 0}}, &data)
 	assert.NoError(t, err)
 
 	assert.Equal(t, []bson.M{
@@ -103,8 +132,7 @@ func TestCollectionBulk(t *testing.T) {
 }
 
 func TestCollectionFetch(t *testing.T) {
-	dbc := db.C("test-coll-fetch")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-coll-fetch")
 
 	id, err := jqc.Enqueue("foo", bson.M{"bar": "baz"}, 0)
 	assert.NoError(t, err)
@@ -128,12 +156,18 @@ func TestCollectionFetch(t *testing.T) {
 		Ended:    setTime,
 		Result:   bson.M{"bar": "baz"},
 		Attempts: 1,
+		History: []Attempt{
+			{
+				Started: setTime,
+				Ended:   setTime,
+				Status:  StatusCompleted,
+			},
+		},
 	}, replaceTimeJob(job))
 }
 
 func TestCollectionDequeue(t *testing.T) {
-	dbc := db.C("test-coll-dequeue")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-coll-dequeue")
 
 	_, err := jqc.Enqueue("foo", bson.M{"bar": "baz"}, 0)
 	assert.NoError(t, err)
@@ -151,8 +185,7 @@ func TestCollectionDequeue(t *testing.T) {
 }
 
 func TestCollectionDequeueDelayed(t *testing.T) {
-	dbc := db.C("test-coll-dequeue-delayed")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-coll-dequeue-delayed")
 
 	_, err := jqc.Enqueue("foo", bson.M{"bar": "baz"}, 100*time.Millisecond)
 	assert.NoError(t, err)
@@ -176,8 +209,7 @@ func TestCollectionDequeueDelayed(t *testing.T) {
 }
 
 func TestCollectionDequeueTimeout(t *testing.T) {
-	dbc := db.C("test-coll-dequeue-timeout")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-coll-dequeue-timeout")
 
 	_, err := jqc.Enqueue("foo", nil, 0)
 	assert.NoError(t, err)
@@ -195,9 +227,33 @@ func TestCollectionDequeueTimeout(t *testing.T) {
 	assert.NotNil(t, job)
 }
 
+func TestCollectionDequeueJobTimeout(t *testing.T) {
+	jqc := newCollection("test-coll-dequeue-job-timeout")
+
+	_, err := jqc.EnqueueWith("foo", nil, Options{
+		Timeout: 100 * time.Millisecond,
+	})
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+	assert.Equal(t, 100*time.Millisecond, job.Timeout)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+
+	time.Sleep(120 * time.Millisecond)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+	assert.Equal(t, 2, job.Attempts)
+}
+
 func TestCollectionDequeueOldFirst(t *testing.T) {
-	dbc := db.C("test-coll-dequeue-old-first")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-coll-dequeue-old-first")
 
 	_, err := jqc.Enqueue("foo", bson.M{"first": true}, 0)
 	assert.NoError(t, err)
@@ -226,8 +282,7 @@ func TestCollectionDequeueOldFirst(t *testing.T) {
 }
 
 func TestCollectionDequeueFailed(t *testing.T) {
-	dbc := db.C("test-coll-dequeue-failed")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-coll-dequeue-failed")
 
 	_, err := jqc.Enqueue("foo", bson.M{"bar": "baz"}, 0)
 	assert.NoError(t, err)
@@ -251,8 +306,7 @@ func TestCollectionDequeueFailed(t *testing.T) {
 }
 
 func TestCollectionDequeueFailedDelayed(t *testing.T) {
-	dbc := db.C("test-coll-dequeue-failed-delayed")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-coll-dequeue-failed-delayed")
 
 	_, err := jqc.Enqueue("foo", bson.M{"bar": "baz"}, 0)
 	assert.NoError(t, err)
@@ -281,18 +335,164 @@ func TestCollectionDequeueFailedDelayed(t *testing.T) {
 	assert.Equal(t, 2, job3.Attempts)
 }
 
+func TestCollectionDequeueExpired(t *testing.T) {
+	jqc := newCollection("test-coll-dequeue-expired")
+
+	_, err := jqc.EnqueueWith("foo", nil, Options{
+		Expires: time.Now().Add(100 * time.Millisecond),
+	})
+	assert.NoError(t, err)
+
+	time.Sleep(120 * time.Millisecond)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+}
+
+func TestCollectionDequeueAs(t *testing.T) {
+	jqc := newCollection("test-coll-dequeue-as")
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.DequeueAs("w1", []string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "w1", job.Worker)
+	assert.Equal(t, "w1", job.History[0].Worker)
+
+	jobs, err := jqc.Owned("w1")
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, id, jobs[0].ID)
+
+	jobs, err = jqc.Owned("w2")
+	assert.NoError(t, err)
+	assert.Empty(t, jobs)
+
+	err = jqc.Complete(id, nil)
+	assert.NoError(t, err)
+
+	job, err = jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Empty(t, job.Worker)
+	assert.Equal(t, "w1", job.History[0].Worker)
+
+	jobs, err = jqc.Owned("w1")
+	assert.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+func TestCollectionDequeueFrom(t *testing.T) {
+	jqc := newCollection("test-coll-dequeue-from")
+
+	jqc.Define("bar", JobDefinition{
+		Queue: "q2",
+	})
+
+	id1, err := jqc.EnqueueWith("foo", nil, Options{Queue: "q1"})
+	assert.NoError(t, err)
+
+	id2, err := jqc.Enqueue("bar", nil, 0)
+	assert.NoError(t, err)
+
+	_, err = jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.DequeueFrom("w1", []string{"q2"}, []string{"foo", "bar"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id2, job.ID)
+
+	job, err = jqc.DequeueFrom("w1", []string{"q2"}, []string{"foo", "bar"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+
+	job, err = jqc.DequeueFrom("w1", []string{"q1", "q2"}, []string{"foo", "bar"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id1, job.ID)
+
+	job, err = jqc.DequeueFrom("w1", nil, []string{"foo", "bar"}, time.Hour)
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+}
+
 func TestCollectionDequeuePanic(t *testing.T) {
-	dbc := db.C("test-coll-dequeue-panic")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-coll-dequeue-panic")
 
 	assert.Panics(t, func() {
 		jqc.Dequeue(nil, 0)
 	})
 }
 
+func TestCollectionProgress(t *testing.T) {
+	jqc := newCollection("test-coll-progress")
+
+	id, err := jqc.EnqueueWith("foo", nil, Options{
+		Timeout: 100 * time.Millisecond,
+	})
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+	assert.Nil(t, job.Progress)
+
+	time.Sleep(60 * time.Millisecond)
+
+	err = jqc.Progress(id, 43, "exporting")
+	assert.NoError(t, err)
+
+	time.Sleep(60 * time.Millisecond)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+
+	job, err = jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, 43.0, job.Progress.Percent)
+	assert.Equal(t, "exporting", job.Progress.Message)
+	assert.False(t, job.Progress.Updated.IsZero())
+
+	time.Sleep(60 * time.Millisecond)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+	assert.Equal(t, 2, job.Attempts)
+	assert.Nil(t, job.Progress)
+
+	job, err = jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Nil(t, job.Progress)
+}
+
+func TestCollectionCheckpoint(t *testing.T) {
+	jqc := newCollection("test-coll-checkpoint")
+
+	_, err := jqc.Enqueue("foo", bson.M{"bar": "baz"}, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Nil(t, job.Checkpoint)
+
+	err = jqc.Checkpoint(job.ID, bson.M{"step": 900})
+	assert.NoError(t, err)
+
+	err = jqc.Fail(job.ID, "some error", 0)
+	assert.NoError(t, err)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"bar": "baz"}, job.Params)
+	assert.Equal(t, bson.M{"step": 900}, job.Checkpoint)
+	assert.Nil(t, job.Result)
+}
+
 func TestCollectionComplete(t *testing.T) {
-	dbc := db.C("test-coll-complete")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-coll-complete")
+	dbc := jqc.jobs
 
 	_, err := jqc.Enqueue("foo", bson.M{"bar": "baz"}, 0)
 	assert.NoError(t, err)
@@ -305,7 +505,7 @@ func TestCollectionComplete(t *testing.T) {
 	assert.NoError(t, err)
 
 	var data bson.M
-	err = dbc.FindId(job.ID).Select(bson.M{"_id": 0}).One(&data)
+	err = dbc.FindOne(bson.M{"_id": job.ID}, FindOptions{Select: bson.M{"_id": 0}}, &data)
 	assert.NoError(t, err)
 	assert.Equal(t, bson.M{
 		"name": "foo",
@@ -321,12 +521,19 @@ func TestCollectionComplete(t *testing.T) {
 			"bar": "baz",
 		},
 		"ended": setTime,
+		"history": []interface{}{
+			bson.M{
+				"started": setTime,
+				"ended":   setTime,
+				"status":  "completed",
+			},
+		},
 	}, replaceTimeMap(data))
 }
 
 func TestCollectionFail(t *testing.T) {
-	dbc := db.C("test-coll-fail")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-coll-fail")
+	dbc := jqc.jobs
 
 	_, err := jqc.Enqueue("foo", bson.M{"bar": "baz"}, 0)
 	assert.NoError(t, err)
@@ -339,7 +546,7 @@ func TestCollectionFail(t *testing.T) {
 	assert.NoError(t, err)
 
 	var data bson.M
-	err = dbc.FindId(job.ID).Select(bson.M{"_id": 0}).One(&data)
+	err = dbc.FindOne(bson.M{"_id": job.ID}, FindOptions{Select: bson.M{"_id": 0}}, &data)
 	assert.NoError(t, err)
 	assert.Equal(t, bson.M{
 		"name": "foo",
@@ -353,12 +560,20 @@ func TestCollectionFail(t *testing.T) {
 		"started":  setTime,
 		"error":    "some error",
 		"ended":    setTime,
+		"history": []interface{}{
+			bson.M{
+				"started": setTime,
+				"ended":   setTime,
+				"status":  "failed",
+				"error":   "some error",
+			},
+		},
 	}, replaceTimeMap(data))
 }
 
 func TestCollectionCancel(t *testing.T) {
-	dbc := db.C("test-coll-cancel")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-coll-cancel")
+	dbc := jqc.jobs
 
 	_, err := jqc.Enqueue("foo", bson.M{"bar": "baz"}, 0)
 	assert.NoError(t, err)
@@ -371,7 +586,7 @@ func TestCollectionCancel(t *testing.T) {
 	assert.NoError(t, err)
 
 	var data bson.M
-	err = dbc.FindId(job.ID).Select(bson.M{"_id": 0}).One(&data)
+	err = dbc.FindOne(bson.M{"_id": job.ID}, FindOptions{Select: bson.M{"_id": 0}}, &data)
 	assert.NoError(t, err)
 	assert.Equal(t, bson.M{
 		"name": "foo",
@@ -385,12 +600,80 @@ func TestCollectionCancel(t *testing.T) {
 		"started":  setTime,
 		"reason":   "some reason",
 		"ended":    setTime,
+		"history": []interface{}{
+			bson.M{
+				"started": setTime,
+				"ended":   setTime,
+				"status":  "cancelled",
+				"reason":  "some reason",
+			},
+		},
 	}, replaceTimeMap(data))
 }
 
+func TestCollectionSweep(t *testing.T) {
+	jqc := newCollection("test-coll-sweep")
+
+	id1, err := jqc.EnqueueWith("foo", nil, Options{
+		Expires: time.Now().Add(100 * time.Millisecond),
+	})
+	assert.NoError(t, err)
+
+	id2, err := jqc.EnqueueWith("foo", nil, Options{
+		Expires: time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+
+	n, err := jqc.Sweep(time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	time.Sleep(120 * time.Millisecond)
+
+	n, err = jqc.Sweep(time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	job, err := jqc.Fetch(id1)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusExpired, job.Status)
+	assert.False(t, job.Ended.IsZero())
+
+	id3, err := jqc.EnqueueWith("bar", nil, Options{
+		Expires: time.Now().Add(50 * time.Millisecond),
+		Timeout: 100 * time.Millisecond,
+	})
+	assert.NoError(t, err)
+
+	job, err = jqc.DequeueAs("w1", []string{"bar"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id3, job.ID)
+
+	time.Sleep(60 * time.Millisecond)
+
+	n, err = jqc.Sweep(time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	time.Sleep(60 * time.Millisecond)
+
+	n, err = jqc.Sweep(time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	job, err = jqc.Fetch(id3)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusExpired, job.Status)
+	assert.Empty(t, job.Worker)
+	assert.Equal(t, StatusExpired, job.History[0].Status)
+
+	job, err = jqc.Fetch(id2)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusEnqueued, job.Status)
+}
+
 func TestCollectionEnsureIndexes(t *testing.T) {
-	dbc := db.C("test-coll-ensure-indexes")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-coll-ensure-indexes")
 
 	assert.NoError(t, jqc.EnsureIndexes(7 * 24 * time.Hour))
 	assert.NoError(t, jqc.EnsureIndexes(7 * 24 * time.Hour))
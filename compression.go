@@ -0,0 +1,201 @@
+package mgojq
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// The available compression codecs.
+const (
+	CodecGzip   = "gzip"
+	CodecSnappy = "snappy"
+	CodecZstd   = "zstd"
+)
+
+// A Blob is a document that has been compressed with the recorded codec.
+type Blob struct {
+	Codec string
+	Data  []byte
+}
+
+// Compressed holds the compressed params and result of a job.
+type Compressed struct {
+	Params *Blob `bson:",omitempty"`
+	Result *Blob `bson:",omitempty"`
+}
+
+var zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+	return zstd.NewWriter(nil)
+})
+
+var zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+	return zstd.NewReader(nil)
+})
+
+// SetCompression will enable the compression of params and results whose
+// encoded size exceeds the specified threshold in bytes using the specified
+// codec. The codec is recorded with every compressed value so that documents
+// written with other codecs or without compression are still decoded. If
+// encryption is enabled, values are compressed before they are encrypted.
+// Compressed params cannot be queried. Compression must be enabled before the
+// collection is used.
+func (c *Collection) SetCompression(codec string, threshold int) {
+	// check codec
+	switch codec {
+	case CodecGzip, CodecSnappy, CodecZstd:
+	default:
+		panic("unsupported codec: " + codec)
+	}
+
+	c.codec = codec
+	c.minSize = threshold
+}
+
+func (c *Collection) compress(data []byte) (string, []byte, error) {
+	// check codec and size
+	if c.codec == "" || len(data) <= c.minSize {
+		return "", data, nil
+	}
+
+	// compress data
+	out, err := compress(c.codec, data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return c.codec, out, nil
+}
+
+func (c *Collection) compressDoc(doc bson.M) (*Blob, error) {
+	// check codec
+	if c.codec == "" || doc == nil {
+		return nil, nil
+	}
+
+	// marshal document
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	// compress data
+	codec, data, err := c.compress(data)
+	if err != nil || codec == "" {
+		return nil, err
+	}
+
+	return &Blob{Codec: codec, Data: data}, nil
+}
+
+func (c *Collection) compressResult(update bson.M) error {
+	// get result
+	set := update["$set"].(bson.M)
+	result, _ := set["result"].(bson.M)
+
+	// compress result
+	blob, err := c.compressDoc(result)
+	if err != nil || blob == nil {
+		return err
+	}
+
+	// replace result
+	delete(set, "result")
+	set["compressed.result"] = blob
+	update["$unset"].(bson.M)["result"] = ""
+
+	return nil
+}
+
+func (c *Collection) decompress(job *Job) error {
+	// check values
+	cmp := job.Compressed
+	if cmp == nil {
+		return nil
+	}
+
+	// decompress params
+	if cmp.Params != nil {
+		data, err := decompress(cmp.Params.Codec, cmp.Params.Data)
+		if err != nil {
+			return err
+		}
+		err = bson.Unmarshal(data, &job.Params)
+		if err != nil {
+			return err
+		}
+	}
+
+	// decompress result
+	if cmp.Result != nil {
+		data, err := decompress(cmp.Result.Codec, cmp.Result.Data)
+		if err != nil {
+			return err
+		}
+		err = bson.Unmarshal(data, &job.Result)
+		if err != nil {
+			return err
+		}
+	}
+
+	// clear values
+	job.Compressed = nil
+
+	return nil
+}
+
+func compress(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case CodecGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		_, err := w.Write(data)
+		if err != nil {
+			return nil, err
+		}
+		err = w.Close()
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CodecSnappy:
+		return snappy.Encode(nil, data), nil
+	case CodecZstd:
+		enc, err := zstdEncoder()
+		if err != nil {
+			return nil, err
+		}
+		return enc.EncodeAll(data, nil), nil
+	}
+
+	return nil, fmt.Errorf("unsupported codec: %s", codec)
+}
+
+func decompress(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case "":
+		return data, nil
+	case CodecGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	case CodecSnappy:
+		return snappy.Decode(nil, data)
+	case CodecZstd:
+		dec, err := zstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+		return dec.DecodeAll(data, nil)
+	}
+
+	return nil, fmt.Errorf("unsupported codec: %s", codec)
+}
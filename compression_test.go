@@ -0,0 +1,120 @@
+package mgojq
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompress(t *testing.T) {
+	data := []byte(strings.Repeat("foo", 100))
+
+	for _, codec := range []string{CodecGzip, CodecSnappy, CodecZstd} {
+		out, err := compress(codec, data)
+		assert.NoError(t, err)
+		assert.True(t, len(out) < len(data), codec)
+
+		out, err = decompress(codec, out)
+		assert.NoError(t, err)
+		assert.Equal(t, data, out, codec)
+	}
+
+	_, err := compress("foo", data)
+	assert.Error(t, err)
+
+	_, err = decompress("foo", data)
+	assert.Error(t, err)
+}
+
+func TestCollectionSetCompression(t *testing.T) {
+	jqc := newCollection("test-collection-set-compression")
+	dbc := jqc.jobs
+
+	assert.Panics(t, func() {
+		jqc.SetCompression("foo", 0)
+	})
+
+	large := strings.Repeat("x", 200)
+
+	id1, err := jqc.Enqueue("foo", bson.M{"data": large}, 0)
+	assert.NoError(t, err)
+
+	jqc.SetCompression(CodecGzip, 100)
+
+	id2, err := jqc.Enqueue("foo", bson.M{"data": large}, 0)
+	assert.NoError(t, err)
+
+	jqc.SetCompression(CodecZstd, 100)
+
+	id3, err := jqc.Enqueue("foo", bson.M{"data": large}, 0)
+	assert.NoError(t, err)
+
+	id4, err := jqc.Enqueue("foo", bson.M{"data": "small"}, 0)
+	assert.NoError(t, err)
+
+	var doc bson.M
+	err = dbc.FindOne(bson.M{"_id": id2}, FindOptions{}, &doc)
+	assert.NoError(t, err)
+	assert.Empty(t, doc["params"])
+	assert.Equal(t, CodecGzip, doc["compressed"].(bson.M)["params"].(bson.M)["codec"])
+
+	err = dbc.FindOne(bson.M{"_id": id4}, FindOptions{}, &doc)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"data": "small"}, doc["params"])
+	assert.Nil(t, doc["compressed"])
+
+	for _, id := range []bson.ObjectId{id1, id2, id3} {
+		job, err := jqc.Fetch(id)
+		assert.NoError(t, err)
+		assert.Equal(t, bson.M{"data": large}, job.Params)
+		assert.Nil(t, job.Compressed)
+	}
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id1, job.ID)
+
+	err = jqc.Complete(id1, bson.M{"data": large})
+	assert.NoError(t, err)
+
+	err = dbc.FindOne(bson.M{"_id": id1}, FindOptions{}, &doc)
+	assert.NoError(t, err)
+	assert.Nil(t, doc["result"])
+	assert.Equal(t, CodecZstd, doc["compressed"].(bson.M)["result"].(bson.M)["codec"])
+
+	job, err = jqc.Fetch(id1)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"data": large}, job.Result)
+}
+
+func TestCompressionEncryption(t *testing.T) {
+	jqc := newCollection("test-compression-encryption")
+	dbc := jqc.jobs
+
+	jqc.SetCompression(CodecSnappy, 100)
+	jqc.SetEncryption(&Keyring{
+		Current: "k1",
+		Keys: map[string][]byte{
+			"k1": bytes.Repeat([]byte{1}, 32),
+		},
+	})
+
+	large := strings.Repeat("x", 200)
+
+	id, err := jqc.Enqueue("foo", bson.M{"data": large}, 0)
+	assert.NoError(t, err)
+
+	var doc bson.M
+	err = dbc.FindOne(bson.M{"_id": id}, FindOptions{}, &doc)
+	assert.NoError(t, err)
+	assert.Nil(t, doc["compressed"])
+	assert.Equal(t, CodecSnappy, doc["encrypted"].(bson.M)["params"].(bson.M)["codec"])
+
+	job, err := jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"data": large}, job.Params)
+}
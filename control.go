@@ -0,0 +1,69 @@
+package mgojq
+
+import (
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// Pause will pause the processing of jobs with the specified name. Jobs can
+// still be enqueued, but will not be dequeued until the name is resumed. The
+// pause applies to all processes that use the same collection.
+func (c *Collection) Pause(name string) error {
+	return c.control().Upsert(bson.M{"_id": "pause"}, bson.M{
+		"$addToSet": bson.M{
+			"names": name,
+		},
+	})
+}
+
+// Resume will resume the processing of jobs with the specified name.
+func (c *Collection) Resume(name string) error {
+	return c.control().Upsert(bson.M{"_id": "pause"}, bson.M{
+		"$pull": bson.M{
+			"names": name,
+		},
+	})
+}
+
+// Paused will return the names of all currently paused jobs.
+func (c *Collection) Paused() ([]string, error) {
+	var doc struct {
+		Names []string
+	}
+	err := c.control().FindOne(bson.M{"_id": "pause"}, FindOptions{}, &doc)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return doc.Names, nil
+}
+
+func (c *Collection) control() Table {
+	return c.store.Table("control")
+}
+
+func without(names, exclude []string) []string {
+	// check exclude
+	if len(exclude) == 0 {
+		return names
+	}
+
+	// collect names that are not excluded
+	list := make([]string, 0, len(names))
+	for _, name := range names {
+		excluded := false
+		for _, ex := range exclude {
+			if name == ex {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			list = append(list, name)
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,66 @@
+package mgojq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionPause(t *testing.T) {
+	jqc := newCollection("test-coll-pause")
+
+	paused, err := jqc.Paused()
+	assert.NoError(t, err)
+	assert.Empty(t, paused)
+
+	_, err = jqc.Enqueue("foo", bson.M{"bar": "baz"}, 0)
+	assert.NoError(t, err)
+
+	err = jqc.Pause("foo")
+	assert.NoError(t, err)
+
+	err = jqc.Pause("foo")
+	assert.NoError(t, err)
+
+	paused, err = jqc.Paused()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"foo"}, paused)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+
+	err = jqc.Resume("foo")
+	assert.NoError(t, err)
+
+	paused, err = jqc.Paused()
+	assert.NoError(t, err)
+	assert.Empty(t, paused)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+}
+
+func TestCollectionPauseOther(t *testing.T) {
+	jqc := newCollection("test-coll-pause-other")
+
+	_, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	_, err = jqc.Enqueue("bar", nil, 0)
+	assert.NoError(t, err)
+
+	err = jqc.Pause("foo")
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo", "bar"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", job.Name)
+
+	job, err = jqc.Dequeue([]string{"foo", "bar"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+}
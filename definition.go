@@ -0,0 +1,152 @@
+package mgojq
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// The reason that is set on jobs that have been cancelled because they failed
+// too many times.
+const exhaustedReason = "max attempts reached"
+
+// A JobDefinition holds the defaults for all jobs with a given name. The
+// defaults are applied when the job is enqueued and stored on the job. Options
+// supplied to EnqueueWith override the defaults.
+type JobDefinition struct {
+	// The number of attempts after which a failed job is cancelled instead.
+	MaxAttempts int
+
+	// The duration after which a dequeued job is considered abandoned. It is
+	// also used as the deadline of the job when it is processed by a pool.
+	// Jobs that have been enqueued without a timeout receive it when they
+	// are dequeued.
+	Timeout time.Duration
+
+	// The priority of the job. Jobs with a higher priority are dequeued
+	// before other jobs. Priorities must not be negative.
+	Priority int
+
+	// The function that is used by Fail, Bulk.Fail and Reclaim to calculate
+	// the delay of the next attempt if no delay has been specified. It
+	// receives the number of attempts so far.
+	Backoff func(attempts int) time.Duration
+
+	// The queue the job belongs to. Pools and DequeueFrom can be restricted
+	// to jobs from specific queues.
+	Queue string
+
+	// The schema the params are checked against before the job is enqueued.
+	Schema *Schema
+
+	// The function that is called with the params before the job is
+	// enqueued. A returned error rejects the job.
+	Validate func(params bson.M) error
+}
+
+// Define will set the definition for the specified job name. Definitions must
+// be set before the collection is used.
+func (c *Collection) Define(name string, def JobDefinition) {
+	// check priority
+	if def.Priority < 0 {
+		panic("priority must not be negative")
+	}
+
+	c.definitions[name] = def
+}
+
+// Definition will return the definition for the specified job name.
+func (c *Collection) Definition(name string) (JobDefinition, bool) {
+	def, ok := c.definitions[name]
+	return def, ok
+}
+
+// ExponentialBackoff returns a backoff function that doubles the specified
+// base delay with every attempt up to the specified maximum delay.
+func ExponentialBackoff(base, max time.Duration) func(int) time.Duration {
+	return func(attempts int) time.Duration {
+		// calculate delay
+		delay := base
+		for i := 1; i < attempts && delay < max; i++ {
+			delay *= 2
+		}
+
+		// cap delay
+		if delay > max {
+			delay = max
+		}
+
+		return delay
+	}
+}
+
+func (c *Collection) defaults(name string, opts Options) Options {
+	// get definition
+	def, ok := c.definitions[name]
+	if !ok {
+		return opts
+	}
+
+	// apply defaults
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = def.MaxAttempts
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = def.Timeout
+	}
+	if opts.Priority == 0 {
+		opts.Priority = def.Priority
+	}
+	if opts.Queue == "" {
+		opts.Queue = def.Queue
+	}
+
+	return opts
+}
+
+func (c *Collection) prepareFail(id bson.ObjectId, error string, delay time.Duration) (string, bson.M, error) {
+	// get job
+	var job Job
+	err := c.jobs.FindOne(bson.M{"_id": id}, FindOptions{
+		Select: bson.M{
+			"name":        1,
+			"attempts":    1,
+			"maxattempts": 1,
+		},
+	}, &job)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return c.failAttempt(job, error, delay)
+}
+
+func (c *Collection) failAttempt(job Job, error string, delay time.Duration) (string, bson.M, error) {
+	// cancel job if all attempts have been used
+	if job.MaxAttempts > 0 && job.Attempts >= job.MaxAttempts {
+		update, err := c.exhaustJob(job.ID, error)
+		return EventCancelled, update, err
+	}
+
+	// apply backoff
+	if def, ok := c.definitions[job.Name]; ok && delay <= 0 && def.Backoff != nil {
+		delay = def.Backoff(job.Attempts)
+	}
+
+	update, err := c.failJob(job.ID, error, delay)
+	return EventFailed, update, err
+}
+
+func (c *Collection) exhaustJob(id bson.ObjectId, error string) (bson.M, error) {
+	return c.sealError(id, bson.M{
+		"$set": bson.M{
+			"status": StatusCancelled,
+			"error":  error,
+			"reason": exhaustedReason,
+			"ended":  time.Now(),
+		},
+		"$unset": bson.M{
+			"worker": "",
+		},
+	})
+}
@@ -0,0 +1,246 @@
+package mgojq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionDefine(t *testing.T) {
+	jqc := newCollection("test-collection-define")
+
+	jqc.Define("foo", JobDefinition{
+		MaxAttempts: 3,
+		Timeout:     time.Minute,
+		Priority:    5,
+		Queue:       "bar",
+	})
+
+	def, ok := jqc.Definition("foo")
+	assert.True(t, ok)
+	assert.Equal(t, 3, def.MaxAttempts)
+
+	_, ok = jqc.Definition("bar")
+	assert.False(t, ok)
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, job.MaxAttempts)
+	assert.Equal(t, time.Minute, job.Timeout)
+	assert.Equal(t, 5, job.Priority)
+	assert.Equal(t, "bar", job.Queue)
+
+	id, err = jqc.EnqueueWith("foo", nil, Options{
+		MaxAttempts: 1,
+		Priority:    7,
+	})
+	assert.NoError(t, err)
+
+	job, err = jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, job.MaxAttempts)
+	assert.Equal(t, time.Minute, job.Timeout)
+	assert.Equal(t, 7, job.Priority)
+	assert.Equal(t, "bar", job.Queue)
+
+	jobs, _, err := jqc.List(Query{
+		Filter: Filter{
+			Queues: []string{"bar"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 2)
+
+	assert.Panics(t, func() {
+		jqc.Define("foo", JobDefinition{Priority: -1})
+	})
+
+	assert.Panics(t, func() {
+		jqc.EnqueueWith("foo", nil, Options{Priority: -5})
+	})
+
+	assert.Panics(t, func() {
+		jqc.Bulk().EnqueueWith("foo", nil, Options{Priority: -5})
+	})
+}
+
+func TestDequeuePriority(t *testing.T) {
+	jqc := newCollection("test-dequeue-priority")
+
+	jqc.Define("bar", JobDefinition{Priority: 1})
+
+	id1, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	id2, err := jqc.Enqueue("bar", nil, 0)
+	assert.NoError(t, err)
+
+	id3, err := jqc.EnqueueWith("foo", nil, Options{Priority: 2})
+	assert.NoError(t, err)
+
+	for _, id := range []bson.ObjectId{id3, id2, id1} {
+		job, err := jqc.Dequeue([]string{"foo", "bar"}, time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, id, job.ID)
+	}
+}
+
+func TestFailBackoffAndMaxAttempts(t *testing.T) {
+	jqc := newCollection("test-fail-backoff-and-max-attempts")
+
+	jqc.Define("foo", JobDefinition{
+		MaxAttempts: 2,
+		Backoff:     ExponentialBackoff(time.Hour, 2*time.Hour),
+	})
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	_, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+
+	err = jqc.Fail(id, "some error", 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusFailed, job.Status)
+	assert.True(t, job.Delayed.After(time.Now().Add(59*time.Minute)))
+
+	n, err := jqc.RescheduleWhere(Filter{Names: []string{"foo"}}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, job.Attempts)
+
+	err = jqc.Fail(id, "some error", 0)
+	assert.NoError(t, err)
+
+	job, err = jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCancelled, job.Status)
+	assert.Equal(t, "some error", job.Error)
+	assert.Equal(t, "max attempts reached", job.Reason)
+}
+
+func TestBulkFailBackoffAndMaxAttempts(t *testing.T) {
+	jqc := newCollection("test-bulk-fail-backoff-and-max-attempts")
+
+	jqc.Define("foo", JobDefinition{
+		MaxAttempts: 2,
+		Backoff:     ExponentialBackoff(time.Hour, 2*time.Hour),
+	})
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	_, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+
+	bulk := jqc.Bulk()
+	bulk.Fail(id, "some error", 0)
+	assert.NoError(t, bulk.Run())
+
+	job, err := jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusFailed, job.Status)
+	assert.True(t, job.Delayed.After(time.Now().Add(59*time.Minute)))
+
+	n, err := jqc.RescheduleWhere(Filter{Names: []string{"foo"}}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+
+	bulk = jqc.Bulk()
+	bulk.Fail(id, "some error", 0)
+	assert.NoError(t, bulk.Run())
+
+	job, err = jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCancelled, job.Status)
+	assert.Equal(t, "max attempts reached", job.Reason)
+
+	bulk = jqc.Bulk()
+	id2 := bulk.Enqueue("foo", nil, 0)
+	bulk.Fail(bson.NewObjectId(), "some error", 0)
+	assert.NoError(t, bulk.Run())
+
+	job, err = jqc.Fetch(id2)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusEnqueued, job.Status)
+}
+
+func TestReclaimMaxAttempts(t *testing.T) {
+	jqc := newCollection("test-reclaim-max-attempts")
+
+	jqc.Define("foo", JobDefinition{
+		MaxAttempts: 1,
+	})
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	err = jqc.Heartbeat(Registration{ID: "w1"})
+	assert.NoError(t, err)
+
+	_, err = jqc.DequeueAs("w1", []string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+
+	time.Sleep(20 * time.Millisecond)
+
+	n, err := jqc.Reclaim(10 * time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	job, err := jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCancelled, job.Status)
+	assert.Equal(t, "orphaned", job.Error)
+	assert.Equal(t, "max attempts reached", job.Reason)
+}
+
+func TestDefinitionTimeoutFallback(t *testing.T) {
+	jqc := newCollection("test-definition-timeout-fallback")
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	jqc.Define("foo", JobDefinition{
+		Timeout: 50 * time.Millisecond,
+	})
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id, job.ID)
+	assert.Equal(t, 50*time.Millisecond, job.Timeout)
+
+	job, err = jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, 50*time.Millisecond, job.Timeout)
+
+	time.Sleep(60 * time.Millisecond)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id, job.ID)
+	assert.Equal(t, 2, job.Attempts)
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 5*time.Second)
+	assert.Equal(t, time.Second, backoff(0))
+	assert.Equal(t, time.Second, backoff(1))
+	assert.Equal(t, 2*time.Second, backoff(2))
+	assert.Equal(t, 4*time.Second, backoff(3))
+	assert.Equal(t, 5*time.Second, backoff(4))
+	assert.Equal(t, 5*time.Second, backoff(100))
+}
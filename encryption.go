@@ -0,0 +1,270 @@
+package mgojq
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// ErrUnknownKey is returned by a KeyProvider if the requested key is missing.
+var ErrUnknownKey = errors.New("unknown key")
+
+// The reason that is set on dequeued jobs that have been cancelled because their
+// params could not be decrypted, loaded or decompressed.
+const unreadableReason = "unreadable params"
+
+// errUnreadable is returned by claim if the claimed job has been cancelled
+// because it could not be opened.
+var errUnreadable = errors.New("unreadable job")
+
+// A KeyProvider supplies the keys used to encrypt and decrypt jobs. Keys must
+// be 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256.
+type KeyProvider interface {
+	// CurrentKey returns the id and key that is used to encrypt new data.
+	CurrentKey() (string, []byte, error)
+
+	// Key returns the key with the specified id.
+	Key(id string) ([]byte, error)
+}
+
+// A Keyring is a static KeyProvider. Keys can be rotated by adding a new key
+// and making it the current key. Old keys must be kept until all data that
+// has been encrypted with them has been removed.
+type Keyring struct {
+	// The id of the key that is used to encrypt new data.
+	Current string
+
+	// The available keys by id.
+	Keys map[string][]byte
+}
+
+// CurrentKey implements the KeyProvider interface.
+func (r *Keyring) CurrentKey() (string, []byte, error) {
+	key, err := r.Key(r.Current)
+	return r.Current, key, err
+}
+
+// Key implements the KeyProvider interface.
+func (r *Keyring) Key(id string) ([]byte, error) {
+	// get key
+	key, ok := r.Keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	return key, nil
+}
+
+// An Envelope holds the encrypted params, result and error of a job.
+type Envelope struct {
+	Params *Ciphertext `bson:",omitempty"`
+	Result *Ciphertext `bson:",omitempty"`
+	Error  *Ciphertext `bson:",omitempty"`
+}
+
+// A Ciphertext is a value that has been encrypted with AES-GCM using the key
+// with the recorded id. If a codec is recorded, the value has been compressed
+// before it was encrypted.
+type Ciphertext struct {
+	Key   string
+	Codec string `bson:",omitempty"`
+	Data  []byte
+}
+
+// SetEncryption will enable the encryption of params, results and errors using
+// the specified key provider. Encrypted values are stored in the envelope of
+// the job and decrypted transparently by Dequeue, Fetch, List and Owned. The
+// values cannot be queried and errors are not recorded in the attempt history.
+// Encryption must be enabled before the collection is used.
+func (c *Collection) SetEncryption(keys KeyProvider) {
+	c.keys = keys
+}
+
+func (c *Collection) seal(id bson.ObjectId, field string, data []byte) (*Ciphertext, error) {
+	// get key
+	keyID, key, err := c.keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// create cipher
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// generate nonce
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Ciphertext{
+		Key:  keyID,
+		Data: aead.Seal(nonce, nonce, data, []byte(id.Hex()+"/"+field)),
+	}, nil
+}
+
+func (c *Collection) unseal(id bson.ObjectId, field string, ct *Ciphertext) ([]byte, error) {
+	// check provider
+	if c.keys == nil {
+		return nil, fmt.Errorf("encrypted %s of job %s requires a key provider", field, id.Hex())
+	}
+
+	// get key
+	key, err := c.keys.Key(ct.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	// create cipher
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// check data
+	if len(ct.Data) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted %s of job %s is malformed", field, id.Hex())
+	}
+
+	// decrypt data
+	nonce, data := ct.Data[:aead.NonceSize()], ct.Data[aead.NonceSize():]
+	return aead.Open(nil, nonce, data, []byte(id.Hex()+"/"+field))
+}
+
+func (c *Collection) sealDoc(id bson.ObjectId, field string, doc bson.M) (*Ciphertext, error) {
+	// marshal document
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	// compress data
+	codec, data, err := c.compress(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// encrypt data
+	ct, err := c.seal(id, field, data)
+	if err != nil {
+		return nil, err
+	}
+	ct.Codec = codec
+
+	return ct, nil
+}
+
+func (c *Collection) unsealDoc(id bson.ObjectId, field string, ct *Ciphertext, doc *bson.M) error {
+	// decrypt data
+	data, err := c.unseal(id, field, ct)
+	if err != nil {
+		return err
+	}
+
+	// decompress data
+	data, err = decompress(ct.Codec, data)
+	if err != nil {
+		return err
+	}
+
+	return bson.Unmarshal(data, doc)
+}
+
+func (c *Collection) sealError(id bson.ObjectId, update bson.M) (bson.M, error) {
+	// check provider
+	if c.keys == nil {
+		return update, nil
+	}
+
+	// encrypt error
+	set := update["$set"].(bson.M)
+	ct, err := c.seal(id, "error", []byte(set["error"].(string)))
+	if err != nil {
+		return nil, err
+	}
+
+	// replace error
+	delete(set, "error")
+	set["encrypted.error"] = ct
+	update["$unset"].(bson.M)["error"] = ""
+
+	return update, nil
+}
+
+func (c *Collection) open(job *Job) error {
+	// load offloaded values
+	err := c.load(job)
+	if err != nil {
+		return err
+	}
+
+	// decompress values
+	err = c.decompress(job)
+	if err != nil {
+		return err
+	}
+
+	// check envelope
+	env := job.Encrypted
+	if env == nil {
+		return nil
+	}
+
+	// decrypt params
+	if env.Params != nil {
+		err = c.unsealDoc(job.ID, "params", env.Params, &job.Params)
+		if err != nil {
+			return err
+		}
+	}
+
+	// decrypt result
+	if env.Result != nil {
+		err = c.unsealDoc(job.ID, "result", env.Result, &job.Result)
+		if err != nil {
+			return err
+		}
+	}
+
+	// decrypt error
+	if env.Error != nil {
+		data, err := c.unseal(job.ID, "error", env.Error)
+		if err != nil {
+			return err
+		}
+		job.Error = string(data)
+	}
+
+	// clear envelope
+	job.Encrypted = nil
+
+	return nil
+}
+
+func (c *Collection) openAll(jobs []Job) error {
+	for i := range jobs {
+		err := c.open(&jobs[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	// create block cipher
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,151 @@
+package mgojq
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionSetEncryption(t *testing.T) {
+	jqc := newCollection("test-collection-set-encryption")
+	dbc := jqc.jobs
+
+	keys := &Keyring{
+		Current: "k1",
+		Keys: map[string][]byte{
+			"k1": bytes.Repeat([]byte{1}, 32),
+		},
+	}
+	jqc.SetEncryption(keys)
+
+	id, err := jqc.Enqueue("foo", bson.M{"email": "foo@example.com"}, 0)
+	assert.NoError(t, err)
+
+	var doc bson.M
+	err = dbc.FindOne(bson.M{"_id": id}, FindOptions{}, &doc)
+	assert.NoError(t, err)
+	assert.Empty(t, doc["params"])
+	assert.NotContains(t, string(doc["encrypted"].(bson.M)["params"].(bson.M)["data"].([]byte)), "foo@example.com")
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"email": "foo@example.com"}, job.Params)
+	assert.Nil(t, job.Encrypted)
+
+	err = jqc.Fail(id, "secret error", 0)
+	assert.NoError(t, err)
+
+	err = dbc.FindOne(bson.M{"_id": id}, FindOptions{}, &doc)
+	assert.NoError(t, err)
+	assert.Nil(t, doc["error"])
+
+	job, err = jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret error", job.Error)
+
+	keys.Current = "k2"
+	keys.Keys["k2"] = bytes.Repeat([]byte{2}, 32)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id, job.ID)
+
+	err = jqc.Complete(id, bson.M{"token": "secret"})
+	assert.NoError(t, err)
+
+	job, err = jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"email": "foo@example.com"}, job.Params)
+	assert.Equal(t, bson.M{"token": "secret"}, job.Result)
+	assert.Equal(t, "secret error", job.Error)
+
+	jobs, _, err := jqc.List(Query{})
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, bson.M{"email": "foo@example.com"}, jobs[0].Params)
+
+	delete(keys.Keys, "k1")
+
+	_, err = jqc.Fetch(id)
+	assert.Equal(t, ErrUnknownKey, err)
+}
+
+func TestDequeueUnreadable(t *testing.T) {
+	jqc := newCollection("test-dequeue-unreadable")
+	dbc := jqc.jobs
+
+	keys := &Keyring{
+		Current: "k1",
+		Keys: map[string][]byte{
+			"k1": bytes.Repeat([]byte{1}, 32),
+		},
+	}
+	jqc.SetEncryption(keys)
+
+	id1, err := jqc.Enqueue("foo", bson.M{"a": 1}, 0)
+	assert.NoError(t, err)
+
+	keys.Current = "k2"
+	keys.Keys = map[string][]byte{
+		"k2": bytes.Repeat([]byte{2}, 32),
+	}
+
+	id2, err := jqc.Enqueue("foo", bson.M{"a": 2}, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id2, job.ID)
+	assert.Equal(t, bson.M{"a": 2}, job.Params)
+
+	var doc bson.M
+	err = dbc.FindOne(bson.M{"_id": id1}, FindOptions{}, &doc)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCancelled, doc["status"])
+	assert.Equal(t, unreadableReason, doc["reason"])
+	assert.NotEmpty(t, doc["encrypted"].(bson.M)["error"])
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+}
+
+func TestEncryptionBinding(t *testing.T) {
+	jqc := newCollection("test-encryption-binding")
+	dbc := jqc.jobs
+
+	jqc.SetEncryption(&Keyring{
+		Current: "k1",
+		Keys: map[string][]byte{
+			"k1": bytes.Repeat([]byte{1}, 32),
+		},
+	})
+
+	id1, err := jqc.Enqueue("foo", bson.M{"a": 1}, 0)
+	assert.NoError(t, err)
+
+	id2, err := jqc.Enqueue("foo", bson.M{"a": 2}, 0)
+	assert.NoError(t, err)
+
+	job1, err := jqc.Fetch(id1)
+	assert.NoError(t, err)
+
+	var doc bson.M
+	err = dbc.FindOne(bson.M{"_id": id1}, FindOptions{}, &doc)
+	assert.NoError(t, err)
+
+	err = dbc.Update(bson.M{"_id": id2}, bson.M{
+		"$set": bson.M{
+			"encrypted": doc["encrypted"],
+		},
+	})
+	assert.NoError(t, err)
+
+	_, err = jqc.Fetch(id2)
+	assert.Error(t, err)
+
+	assert.Equal(t, bson.M{"a": 1}, job1.Params)
+}
@@ -47,6 +47,94 @@ func Example() {
 	}
 
 	fmt.Printf("%s: %d\n", job.Status, job.Result["r"].(int))
+}
+
+func ExampleNew() {
+	// create in-memory jobs collection
+	coll := New(NewMemoryStore())
+
+	// create a worker pool
+	pool := NewPool(1, 100*time.Millisecond, 1*time.Hour)
+
+	// register worker
+	pool.Register("Adder", func(c *Collection, j *Job, q <-chan struct{}) error {
+		r := j.Params["a"].(int) + j.Params["b"].(int)
+		c.Complete(j.ID, bson.M{"r": r})
+		return nil
+	})
+
+	// start pool
+	pool.Start(coll)
+	defer pool.Close()
+
+	// add job
+	id, err := coll.Enqueue("Adder", bson.M{"a": 10, "b": 5}, 0)
+	if err != nil {
+		panic(err)
+	}
+
+	// wait some time
+	time.Sleep(200 * time.Millisecond)
+
+	// get job
+	job, err := coll.Fetch(id)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("%s: %d\n", job.Status, job.Result["r"].(int))
+
+	// Output:
+	// completed: 15
+}
+
+func ExampleRegisterTyped() {
+	type params struct {
+		A int `bson:"a"`
+		B int `bson:"b"`
+	}
+
+	type result struct {
+		R int `bson:"r"`
+	}
+
+	// create in-memory jobs collection
+	coll := New(NewMemoryStore())
+
+	// create a worker pool
+	pool := NewPool(1, 100*time.Millisecond, 1*time.Hour)
+
+	// register typed worker
+	RegisterTyped(pool, "TypedAdder", func(c *Collection, j *Job, p params, q <-chan struct{}) (result, error) {
+		return result{R: p.A + p.B}, nil
+	})
+
+	// start pool
+	pool.Start(coll)
+	defer pool.Close()
+
+	// add job
+	id, err := EnqueueTyped(coll, "TypedAdder", params{A: 10, B: 5}, 0)
+	if err != nil {
+		panic(err)
+	}
+
+	// wait some time
+	time.Sleep(200 * time.Millisecond)
+
+	// get job
+	job, err := coll.Fetch(id)
+	if err != nil {
+		panic(err)
+	}
+
+	// decode result
+	r, err := DecodeResult[result](job)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("%s: %d\n", job.Status, r.R)
 
 	// Output:
 	// completed: 15
@@ -0,0 +1,88 @@
+package mgojq
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// The default number of attempts recorded per job.
+const defaultHistory = 10
+
+// An Attempt records a single attempt to process a job.
+type Attempt struct {
+	// The time when the job was dequeued.
+	Started time.Time `bson:",omitempty"`
+
+	// The time when the attempt ended.
+	Ended time.Time `bson:",omitempty"`
+
+	// The outcome of the attempt. The status remains "dequeued" while the
+	// attempt is running or if it has been abandoned.
+	Status string
+
+	// The error submitted when the attempt failed.
+	Error string `bson:",omitempty"`
+
+	// The reason submitted when the job was cancelled.
+	Reason string `bson:",omitempty"`
+
+	// The identity of the worker that dequeued the job.
+	Worker string `bson:",omitempty"`
+}
+
+// SetHistory will set the number of attempts that are recorded per job. An
+// attempt is added by Dequeue and updated by Complete, Fail and Cancel if the
+// job is still dequeued. The history is ordered newest first and only the
+// latest attempts are kept. A length of zero disables the history. The default
+// length is 10.
+func (c *Collection) SetHistory(length int) {
+	c.history = length
+}
+
+func (c *Collection) startAttempt(update bson.M, now time.Time, worker string) {
+	// check history
+	if c.history <= 0 {
+		return
+	}
+
+	// prepend attempt and cap history
+	update["$push"] = bson.M{
+		"history": bson.M{
+			"$each": []Attempt{{
+				Started: now,
+				Status:  StatusDequeued,
+				Worker:  worker,
+			}},
+			"$position": 0,
+			"$slice":    c.history,
+		},
+	}
+}
+
+func (c *Collection) endAttempt(update bson.M) bson.M {
+	// check history
+	if c.history <= 0 {
+		return update
+	}
+
+	// copy update
+	set := bson.M{}
+	for key, value := range update["$set"].(bson.M) {
+		set[key] = value
+	}
+	copied := bson.M{}
+	for key, value := range update {
+		copied[key] = value
+	}
+	copied["$set"] = set
+
+	// update latest attempt
+	for _, field := range []string{"status", "ended", "error", "reason"} {
+		if value, ok := set[field]; ok {
+			set["history.0."+field] = value
+		}
+	}
+
+	return copied
+}
@@ -0,0 +1,156 @@
+package mgojq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionHistory(t *testing.T) {
+	jqc := newCollection("test-coll-history")
+	jqc.SetHistory(2)
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+		assert.NoError(t, err)
+		assert.NotNil(t, job)
+
+		err = jqc.Fail(job.ID, "error "+string(rune('1'+i)), 0)
+		assert.NoError(t, err)
+	}
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, []Attempt{
+		{
+			Started: setTime,
+			Status:  StatusDequeued,
+		},
+		{
+			Started: setTime,
+			Ended:   setTime,
+			Status:  StatusFailed,
+			Error:   "error 3",
+		},
+	}, replaceTimeJob(job).History)
+
+	err = jqc.Complete(id, nil)
+	assert.NoError(t, err)
+
+	job, err = jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, job.Attempts)
+	assert.Equal(t, []Attempt{
+		{
+			Started: setTime,
+			Ended:   setTime,
+			Status:  StatusCompleted,
+		},
+		{
+			Started: setTime,
+			Ended:   setTime,
+			Status:  StatusFailed,
+			Error:   "error 3",
+		},
+	}, replaceTimeJob(job).History)
+}
+
+func TestCollectionHistoryDisabled(t *testing.T) {
+	jqc := newCollection("test-coll-history-disabled")
+	jqc.SetHistory(0)
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Empty(t, job.History)
+
+	err = jqc.Complete(id, nil)
+	assert.NoError(t, err)
+
+	job, err = jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Empty(t, job.History)
+}
+
+func TestCollectionHistoryNotDequeued(t *testing.T) {
+	jqc := newCollection("test-coll-history-not-dequeued")
+
+	id1, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	id2, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	err = jqc.Cancel(id1, "some reason")
+	assert.NoError(t, err)
+
+	job, err := jqc.Fetch(id1)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCancelled, job.Status)
+	assert.Empty(t, job.History)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id2, job.ID)
+
+	err = jqc.Fail(id2, "some error", time.Hour)
+	assert.NoError(t, err)
+
+	err = jqc.Cancel(id2, "some reason")
+	assert.NoError(t, err)
+
+	job, err = jqc.Fetch(id2)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCancelled, job.Status)
+	assert.Equal(t, []Attempt{
+		{
+			Started: setTime,
+			Ended:   setTime,
+			Status:  StatusFailed,
+			Error:   "some error",
+		},
+	}, replaceTimeJob(job).History)
+}
+
+func TestCollectionHistoryBulk(t *testing.T) {
+	jqc := newCollection("test-coll-history-bulk")
+
+	id1, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id1, job.ID)
+
+	id2, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	bulk := jqc.Bulk()
+	bulk.Fail(id1, "some error", time.Hour)
+	bulk.Cancel(id2, "some reason")
+	err = bulk.Run()
+	assert.NoError(t, err)
+
+	job, err = jqc.Fetch(id1)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusFailed, job.Status)
+	assert.Equal(t, []Attempt{
+		{
+			Started: setTime,
+			Ended:   setTime,
+			Status:  StatusFailed,
+			Error:   "some error",
+		},
+	}, replaceTimeJob(job).History)
+
+	job, err = jqc.Fetch(id2)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCancelled, job.Status)
+	assert.Empty(t, job.History)
+}
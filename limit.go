@@ -0,0 +1,261 @@
+package mgojq
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// A Limit describes a rate limit that is enforced for a job name using a token
+// bucket. The bucket holds up to Rate tokens and is continuously refilled with
+// Rate tokens per Period. Every dequeued job takes one token. The state of the
+// bucket is stored in the database and therefore shared by all processes.
+type Limit struct {
+	// The maximum number of jobs that can be dequeued per period.
+	Rate int
+
+	// The period in which the bucket is completely refilled.
+	Period time.Duration
+
+	// The optional params key whose value is used to maintain a separate
+	// bucket per distinct value.
+	Key string
+}
+
+type bucket struct {
+	ID      string `bson:"_id"`
+	Tokens  float64
+	Updated time.Time
+	Rev     int
+}
+
+// SetLimit will set a rate limit for the specified job name. Jobs that would
+// exceed the limit are skipped by Dequeue until tokens become available again.
+// Limits must be set before the collection is used.
+func (c *Collection) SetLimit(name string, limit Limit) {
+	// check limit
+	if limit.Rate <= 0 || limit.Period <= 0 {
+		panic("limit rate and period must be positive")
+	}
+
+	c.limits[name] = limit
+}
+
+func (c *Collection) limited(names []string) bool {
+	for _, name := range names {
+		if _, ok := c.limits[name]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Collection) dequeueLimited(worker string, names, queues []string, timeout time.Duration) (*Job, error) {
+	// prepare exclusions
+	var nor []bson.M
+	var skip []bson.ObjectId
+	rejected := map[string]bool{}
+
+	for {
+		// prepare query
+		query := c.available(names, queues, timeout)
+		if len(nor) > 0 {
+			query["$nor"] = nor
+		}
+		if len(skip) > 0 {
+			query["_id"] = bson.M{
+				"$nin": skip,
+			}
+		}
+
+		// find next candidate
+		var candidate Job
+		err := c.jobs.FindOne(query, FindOptions{
+			Sort: []string{"-priority", "_id"},
+			Select: bson.M{
+				"name":              1,
+				"params":            1,
+				"encrypted.params":  1,
+				"compressed.params": 1,
+				"refs.params":       1,
+			},
+		}, &candidate)
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		// check if params can be queried
+		plain := candidate.Encrypted == nil && candidate.Compressed == nil && candidate.Refs == nil
+
+		// get limit
+		limit, limited := c.limits[candidate.Name]
+
+		// load params if they select the bucket, unreadable candidates are
+		// claimed and cancelled without taking a token
+		if limited && limit.Key != "" {
+			err = c.open(&candidate)
+			if err != nil {
+				limited = false
+			}
+		}
+
+		// take token if limited
+		var id string
+		if limited {
+			id = bucketID(candidate, limit)
+			ok := false
+			if !rejected[id] {
+				ok, err = c.take(id, limit)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			// exclude bucket if no token is available
+			if !ok {
+				rejected[id] = true
+				if limit.Key == "" {
+					names = without(names, []string{candidate.Name})
+					if len(names) == 0 {
+						return nil, nil
+					}
+				} else if plain {
+					nor = append(nor, bson.M{
+						"name":                candidate.Name,
+						"params." + limit.Key: candidate.Params[limit.Key],
+					})
+				} else {
+					skip = append(skip, candidate.ID)
+				}
+
+				continue
+			}
+		}
+
+		// claim candidate
+		query["_id"] = candidate.ID
+		job, err := c.claim(worker, query)
+		if err != nil && err != errUnreadable {
+			return nil, err
+		} else if job != nil {
+			return job, nil
+		}
+
+		// return token if candidate has been claimed by someone else or has
+		// been cancelled because it is unreadable
+		if limited {
+			err = c.refund(id, limit)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (c *Collection) take(id string, limit Limit) (bool, error) {
+	for {
+		// get bucket
+		var b bucket
+		err := c.buckets().FindOne(bson.M{"_id": id}, FindOptions{}, &b)
+		if err == mgo.ErrNotFound {
+			// create full bucket and take a token
+			err = c.buckets().Insert(&bucket{
+				ID:      id,
+				Tokens:  float64(limit.Rate) - 1,
+				Updated: time.Now(),
+			})
+			if mgo.IsDup(err) {
+				continue
+			} else if err != nil {
+				return false, err
+			}
+
+			return true, nil
+		} else if err != nil {
+			return false, err
+		}
+
+		// refill bucket
+		now := time.Now()
+		elapsed := float64(now.Sub(b.Updated)) / float64(limit.Period)
+		tokens := math.Min(float64(limit.Rate), b.Tokens+elapsed*float64(limit.Rate))
+
+		// check tokens
+		if tokens < 1 {
+			return false, nil
+		}
+
+		// take token if the bucket has not been changed in the meantime
+		err = c.buckets().Update(bson.M{
+			"_id": id,
+			"rev": b.Rev,
+		}, bson.M{
+			"$set": bson.M{
+				"tokens":  tokens - 1,
+				"updated": now,
+			},
+			"$inc": bson.M{
+				"rev": 1,
+			},
+		})
+		if err == mgo.ErrNotFound {
+			continue
+		} else if err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+}
+
+func (c *Collection) refund(id string, limit Limit) error {
+	for {
+		// get bucket
+		var b bucket
+		err := c.buckets().FindOne(bson.M{"_id": id}, FindOptions{}, &b)
+		if err == mgo.ErrNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		// return token if the bucket has not been changed in the meantime
+		err = c.buckets().Update(bson.M{
+			"_id": id,
+			"rev": b.Rev,
+		}, bson.M{
+			"$set": bson.M{
+				"tokens": math.Min(float64(limit.Rate), b.Tokens+1),
+			},
+			"$inc": bson.M{
+				"rev": 1,
+			},
+		})
+		if err == mgo.ErrNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		return nil
+	}
+}
+
+func (c *Collection) buckets() Table {
+	return c.store.Table("limits")
+}
+
+func bucketID(job Job, limit Limit) string {
+	// check key
+	if limit.Key == "" {
+		return job.Name
+	}
+
+	return fmt.Sprintf("%s/%v", job.Name, job.Params[limit.Key])
+}
@@ -0,0 +1,239 @@
+package mgojq
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionLimit(t *testing.T) {
+	jqc := newCollection("test-coll-limit")
+
+	jqc.SetLimit("foo", Limit{
+		Rate:   2,
+		Period: 200 * time.Millisecond,
+	})
+
+	for i := 0; i < 3; i++ {
+		_, err := jqc.Enqueue("foo", nil, 0)
+		assert.NoError(t, err)
+	}
+
+	_, err := jqc.Enqueue("bar", nil, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo", "bar"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", job.Name)
+
+	job, err = jqc.Dequeue([]string{"foo", "bar"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", job.Name)
+
+	job, err = jqc.Dequeue([]string{"foo", "bar"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", job.Name)
+
+	job, err = jqc.Dequeue([]string{"foo", "bar"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+
+	time.Sleep(120 * time.Millisecond)
+
+	job, err = jqc.Dequeue([]string{"foo", "bar"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", job.Name)
+}
+
+func TestCollectionLimitKey(t *testing.T) {
+	jqc := newCollection("test-coll-limit-key")
+
+	jqc.SetLimit("foo", Limit{
+		Rate:   1,
+		Period: time.Hour,
+		Key:    "tenant",
+	})
+
+	_, err := jqc.Enqueue("foo", bson.M{"tenant": "a"}, 0)
+	assert.NoError(t, err)
+
+	_, err = jqc.Enqueue("foo", bson.M{"tenant": "a"}, 0)
+	assert.NoError(t, err)
+
+	_, err = jqc.Enqueue("foo", bson.M{"tenant": "b"}, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"tenant": "a"}, job.Params)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"tenant": "b"}, job.Params)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+}
+
+func TestCollectionLimitKeyTransformed(t *testing.T) {
+	keys := &Keyring{
+		Current: "k1",
+		Keys: map[string][]byte{
+			"k1": bytes.Repeat([]byte{1}, 32),
+		},
+	}
+
+	table := []struct {
+		name  string
+		setup func(*Collection)
+		db    bool
+	}{
+		{"encryption", func(c *Collection) {
+			c.SetEncryption(keys)
+		}, false},
+		{"compression", func(c *Collection) {
+			c.SetCompression(CodecGzip, 1)
+		}, false},
+		{"offload", func(c *Collection) {
+			c.SetOffload(1)
+		}, true},
+		{"all", func(c *Collection) {
+			c.SetEncryption(keys)
+			c.SetCompression(CodecGzip, 1)
+			c.SetOffload(1)
+		}, true},
+	}
+
+	for _, item := range table {
+		t.Run(item.name, func(t *testing.T) {
+			if item.db {
+				requireDB(t)
+			}
+
+			jqc := newCollection("test-coll-limit-key-" + item.name)
+			item.setup(jqc)
+
+			jqc.SetLimit("foo", Limit{
+				Rate:   1,
+				Period: time.Hour,
+				Key:    "tenant",
+			})
+
+			for _, tenant := range []string{"a", "a", "a", "b"} {
+				_, err := jqc.Enqueue("foo", bson.M{"tenant": tenant}, 0)
+				assert.NoError(t, err)
+			}
+
+			job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+			assert.NoError(t, err)
+			assert.Equal(t, "a", job.Params["tenant"])
+
+			job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+			assert.NoError(t, err)
+			assert.Equal(t, "b", job.Params["tenant"])
+
+			job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+			assert.NoError(t, err)
+			assert.Nil(t, job)
+		})
+	}
+}
+
+func TestCollectionLimitRefund(t *testing.T) {
+	jqc := newCollection("test-coll-limit-refund")
+
+	limit := Limit{
+		Rate:   1,
+		Period: time.Hour,
+	}
+
+	ok, err := jqc.take("foo", limit)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	err = jqc.refund("foo", limit)
+	assert.NoError(t, err)
+
+	err = jqc.refund("foo", limit)
+	assert.NoError(t, err)
+
+	var b bucket
+	err = jqc.buckets().FindOne(bson.M{"_id": "foo"}, FindOptions{}, &b)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, b.Tokens)
+	assert.Equal(t, 2, b.Rev)
+
+	err = jqc.refund("bar", limit)
+	assert.NoError(t, err)
+}
+
+type countingKeys struct {
+	*Keyring
+	reads int
+}
+
+func (k *countingKeys) Key(id string) ([]byte, error) {
+	k.reads++
+	return k.Keyring.Key(id)
+}
+
+func TestCollectionLimitTransformed(t *testing.T) {
+	jqc := newCollection("test-coll-limit-transformed")
+
+	keys := &countingKeys{
+		Keyring: &Keyring{
+			Current: "k1",
+			Keys: map[string][]byte{
+				"k1": bytes.Repeat([]byte{1}, 32),
+			},
+		},
+	}
+	jqc.SetEncryption(keys)
+
+	jqc.SetLimit("foo", Limit{
+		Rate:   1,
+		Period: time.Hour,
+	})
+
+	id1, err := jqc.Enqueue("foo", bson.M{"a": 1}, 0)
+	assert.NoError(t, err)
+
+	keys.Current = "k2"
+	keys.Keys = map[string][]byte{
+		"k2": bytes.Repeat([]byte{2}, 32),
+	}
+
+	id2, err := jqc.Enqueue("foo", bson.M{"a": 2}, 0)
+	assert.NoError(t, err)
+
+	_, err = jqc.Enqueue("foo", bson.M{"a": 3}, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id2, job.ID)
+
+	var doc bson.M
+	err = jqc.jobs.FindOne(bson.M{"_id": id1}, FindOptions{}, &doc)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCancelled, doc["status"])
+
+	keys.reads = 0
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+	assert.Equal(t, 0, keys.reads)
+}
+
+func TestCollectionLimitPanic(t *testing.T) {
+	jqc := newCollection("test-coll-limit-panic")
+
+	assert.Panics(t, func() {
+		jqc.SetLimit("foo", Limit{})
+	})
+}
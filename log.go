@@ -0,0 +1,196 @@
+package mgojq
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// The available log levels.
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// A Log is a log line that has been attached to a job.
+type Log struct {
+	// The unique id of the log.
+	ID bson.ObjectId `bson:"_id"`
+
+	// The id of the job.
+	Job bson.ObjectId
+
+	// The attempt of the job during which the log has been written.
+	Attempt int
+
+	// The time when the log has been written.
+	Time time.Time
+
+	// The level of the log.
+	Level string
+
+	// The message of the log.
+	Message string
+
+	// Additional structured fields.
+	Fields bson.M `bson:",omitempty"`
+
+	// The time when the job has ended. It is kept in sync with the job so
+	// that logs expire together with their job.
+	Ended time.Time `bson:",omitempty"`
+}
+
+// Log will attach a log line to the specified job. The log is stored in a
+// companion collection together with the current attempt of the job. The job
+// is marked as logged so that only the logs of logged jobs are updated when
+// jobs end.
+func (c *Collection) Log(id bson.ObjectId, level, msg string, fields bson.M) error {
+	// mark job and get current attempt
+	var job Job
+	err := c.jobs.Modify(bson.M{"_id": id}, FindOptions{
+		Select: bson.M{
+			"attempts": 1,
+			"ended":    1,
+		},
+	}, bson.M{
+		"$set": bson.M{
+			"logged": true,
+		},
+	}, &job)
+	if err != nil {
+		return err
+	}
+
+	return c.logs().Insert(&Log{
+		ID:      bson.NewObjectId(),
+		Job:     id,
+		Attempt: job.Attempts,
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+		Fields:  fields,
+		Ended:   job.Ended,
+	})
+}
+
+// Logs will return the logs of the specified job in the order they have been
+// written. If after is a valid log id, only logs written after that log are
+// returned. This allows streaming the logs of a running job by polling.
+func (c *Collection) Logs(id, after bson.ObjectId) ([]Log, error) {
+	// prepare query
+	query := bson.M{
+		"job": id,
+	}
+	if after.Valid() {
+		query["_id"] = bson.M{
+			"$gt": after,
+		}
+	}
+
+	var logs []Log
+	err := c.logs().Find(query, FindOptions{
+		Sort: []string{"_id"},
+	}, &logs)
+	if err != nil {
+		return nil, err
+	}
+
+	return logs, nil
+}
+
+func (c *Collection) logs() Table {
+	return c.store.Table("logs")
+}
+
+func (c *Collection) endLogs(ids []bson.ObjectId, ended time.Time) error {
+	// check ids
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// prepare update
+	update := bson.M{
+		"$set": bson.M{
+			"ended": ended,
+		},
+	}
+	if ended.IsZero() {
+		update = bson.M{
+			"$unset": bson.M{
+				"ended": "",
+			},
+		}
+	}
+
+	// update logs
+	_, err := c.logs().UpdateAll(bson.M{
+		"job": bson.M{
+			"$in": ids,
+		},
+	}, update)
+
+	return err
+}
+
+func (c *Collection) endRelated(jobs []Job, updates []bson.M, ended time.Time) error {
+	// collect jobs with logs or payloads, results may have been offloaded by
+	// the updates
+	var logged, offloaded []bson.ObjectId
+	for i, job := range jobs {
+		if job.Logged {
+			logged = append(logged, job.ID)
+		}
+		if job.Refs != nil {
+			offloaded = append(offloaded, job.ID)
+		} else if i < len(updates) && updates[i]["$set"].(bson.M)["refs.result"] != nil {
+			offloaded = append(offloaded, job.ID)
+		}
+	}
+
+	// update logs
+	err := c.endLogs(logged, ended)
+	if err != nil {
+		return err
+	}
+
+	return c.endPayloads(offloaded, ended)
+}
+
+func (c *Collection) updateAll(query, update bson.M, ended time.Time) (int, error) {
+	// find matching jobs
+	var jobs []Job
+	err := c.jobs.Find(query, FindOptions{
+		Select: bson.M{
+			"logged": 1,
+			"refs":   1,
+		},
+	}, &jobs)
+	if err != nil || len(jobs) == 0 {
+		return 0, err
+	}
+
+	// collect ids
+	ids := make([]bson.ObjectId, 0, len(jobs))
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+
+	// update jobs that still match
+	query["_id"] = bson.M{
+		"$in": ids,
+	}
+	n, err := c.jobs.UpdateAll(query, update)
+	if err != nil {
+		return 0, err
+	}
+
+	// update logs and payloads
+	err = c.endRelated(jobs, nil, ended)
+	if err != nil {
+		return n, err
+	}
+
+	return n, nil
+}
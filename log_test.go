@@ -0,0 +1,140 @@
+package mgojq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionLog(t *testing.T) {
+	jqc := newCollection("test-coll-log")
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+
+	err = jqc.Log(id, LevelInfo, "started", bson.M{"step": 1})
+	assert.NoError(t, err)
+
+	err = jqc.Log(id, LevelError, "failed", nil)
+	assert.NoError(t, err)
+
+	logs, err := jqc.Logs(id, "")
+	assert.NoError(t, err)
+	assert.Len(t, logs, 2)
+	assert.Equal(t, id, logs[0].Job)
+	assert.Equal(t, 1, logs[0].Attempt)
+	assert.Equal(t, LevelInfo, logs[0].Level)
+	assert.Equal(t, "started", logs[0].Message)
+	assert.Equal(t, bson.M{"step": 1}, logs[0].Fields)
+	assert.Equal(t, LevelError, logs[1].Level)
+	assert.Equal(t, "failed", logs[1].Message)
+	assert.Nil(t, logs[1].Fields)
+
+	logs, err = jqc.Logs(id, logs[0].ID)
+	assert.NoError(t, err)
+	assert.Len(t, logs, 1)
+	assert.Equal(t, "failed", logs[0].Message)
+
+	err = jqc.Log(bson.NewObjectId(), LevelInfo, "foo", nil)
+	assert.Equal(t, mgo.ErrNotFound, err)
+}
+
+func TestCollectionLogEnded(t *testing.T) {
+	jqc := newCollection("test-coll-log-ended")
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+
+	err = jqc.Log(id, LevelInfo, "started", nil)
+	assert.NoError(t, err)
+
+	logs, err := jqc.Logs(id, "")
+	assert.NoError(t, err)
+	assert.Len(t, logs, 1)
+	assert.True(t, logs[0].Ended.IsZero())
+
+	err = jqc.Cancel(id, "some reason")
+	assert.NoError(t, err)
+
+	err = jqc.Log(id, LevelInfo, "cancelled", nil)
+	assert.NoError(t, err)
+
+	job, err = jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.True(t, job.Logged)
+
+	logs, err = jqc.Logs(id, "")
+	assert.NoError(t, err)
+	assert.Len(t, logs, 2)
+	assert.False(t, logs[0].Ended.Before(job.Ended))
+	assert.False(t, logs[1].Ended.Before(job.Ended))
+
+	n, err := jqc.RetryWhere(Filter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	logs, err = jqc.Logs(id, "")
+	assert.NoError(t, err)
+	assert.Len(t, logs, 2)
+	assert.True(t, logs[0].Ended.IsZero())
+	assert.True(t, logs[1].Ended.IsZero())
+
+	n, err = jqc.CancelWhere(Filter{}, "some reason")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	logs, err = jqc.Logs(id, "")
+	assert.NoError(t, err)
+	assert.False(t, logs[0].Ended.IsZero())
+
+	n, err = jqc.DeleteWhere(Filter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	logs, err = jqc.Logs(id, "")
+	assert.NoError(t, err)
+	assert.Empty(t, logs)
+}
+
+func TestCollectionLogEndedBulk(t *testing.T) {
+	jqc := newCollection("test-coll-log-ended-bulk")
+
+	id1, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	id2, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	err = jqc.Log(id1, LevelInfo, "enqueued", nil)
+	assert.NoError(t, err)
+
+	bulk := jqc.Bulk()
+	bulk.Cancel(id1, "some reason")
+	bulk.Cancel(id2, "some reason")
+	err = bulk.Run()
+	assert.NoError(t, err)
+
+	job, err := jqc.Fetch(id1)
+	assert.NoError(t, err)
+	assert.True(t, job.Logged)
+
+	logs, err := jqc.Logs(id1, "")
+	assert.NoError(t, err)
+	assert.Len(t, logs, 1)
+	assert.False(t, logs[0].Ended.IsZero())
+
+	job, err = jqc.Fetch(id2)
+	assert.NoError(t, err)
+	assert.False(t, job.Logged)
+}
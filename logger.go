@@ -0,0 +1,76 @@
+package mgojq
+
+import (
+	"context"
+	"log/slog"
+
+	"gopkg.in/tomb.v2"
+)
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+var discard = slog.New(discardHandler{})
+
+// SetLogger will set the handler that is used to log job lifecycle events.
+// Enqueued, dequeued and completed jobs are logged at the debug level, failed
+// and cancelled jobs at the info level and reclaimed jobs at the warn level.
+// The logger must be set before the collection is used.
+func (c *Collection) SetLogger(handler slog.Handler) {
+	c.logger = slog.New(handler)
+}
+
+// SetLogger will set the handler that is used to log job processing, timeouts
+// and errors that stop the pool. If no handler is set, the pool uses the
+// logger of the collection. It must be called before the pool is started.
+func (p *Pool) SetLogger(handler slog.Handler) {
+	p.logger = slog.New(handler)
+}
+
+func logEvent(logger *slog.Logger, event Event) {
+	// get level
+	level := slog.LevelDebug
+	switch event.Type {
+	case EventFailed, EventCancelled:
+		level = slog.LevelInfo
+	case EventReclaimed:
+		level = slog.LevelWarn
+	}
+
+	// check level
+	if !logger.Enabled(context.Background(), level) {
+		return
+	}
+
+	// prepare attributes
+	attrs := []slog.Attr{
+		slog.String("id", event.Job.Hex()),
+		slog.String("name", event.Name),
+		slog.Int("attempt", event.Attempt),
+	}
+	if event.Duration > 0 {
+		attrs = append(attrs, slog.Duration("duration", event.Duration))
+	}
+
+	logger.LogAttrs(context.Background(), level, event.Type+" job", attrs...)
+}
+
+func (p *Pool) routine(name string, fn func() error) func() error {
+	return func() error {
+		// run routine
+		err := fn()
+		if err != nil && err != tomb.ErrDying {
+			p.logger.Error("pool routine failed",
+				slog.String("identity", p.identity),
+				slog.String("routine", name),
+				slog.String("error", err.Error()),
+			)
+		}
+
+		return err
+	}
+}
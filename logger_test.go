@@ -0,0 +1,88 @@
+package mgojq
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionSetLogger(t *testing.T) {
+	jqc := newCollection("test-collection-set-logger")
+
+	var buf bytes.Buffer
+	jqc.SetLogger(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	}))
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id, job.ID)
+
+	err = jqc.Fail(id, "some error", 0)
+	assert.NoError(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, "level=DEBUG msg=\"enqueued job\" id="+id.Hex()+" name=foo attempt=0")
+	assert.Contains(t, out, "level=DEBUG msg=\"dequeued job\" id="+id.Hex()+" name=foo attempt=1")
+	assert.Contains(t, out, "level=INFO msg=\"failed job\" id="+id.Hex()+" name=foo attempt=1")
+}
+
+func TestPoolSetLogger(t *testing.T) {
+	jqc := newCollection("test-pool-set-logger")
+
+	var buf bytes.Buffer
+	jqc.SetLogger(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	}))
+
+	pool := NewPool(1, 0, time.Hour)
+	pool.SetIdentity("foo")
+	pool.Register("foo", func(c *Collection, j *Job, quit <-chan struct{}) error {
+		return c.Complete(j.ID, nil)
+	})
+
+	pool.Start(jqc)
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+	pool.Close()
+	assert.NoError(t, pool.Wait())
+
+	out := buf.String()
+	assert.Contains(t, out, "level=INFO msg=\"pool started\" identity=foo size=1 names=[foo]")
+	assert.Contains(t, out, "level=DEBUG msg=\"processing job\" id="+id.Hex()+" name=foo attempt=1")
+	assert.Contains(t, out, "level=DEBUG msg=\"completed job\" id="+id.Hex()+" name=foo attempt=1")
+}
+
+func TestPoolLogTimeout(t *testing.T) {
+	jqc := newCollection("test-pool-log-timeout")
+
+	var buf bytes.Buffer
+	jqc.SetLogger(slog.NewTextHandler(&buf, nil))
+
+	id, err := jqc.EnqueueWith("foo", nil, Options{Timeout: 10 * time.Millisecond})
+	assert.NoError(t, err)
+
+	pool := NewPool(1, 0, time.Hour)
+	pool.Register("foo", func(c *Collection, j *Job, quit <-chan struct{}) error {
+		<-quit
+		return c.Cancel(j.ID, "timeout")
+	})
+
+	pool.Start(jqc)
+
+	time.Sleep(50 * time.Millisecond)
+	pool.Close()
+	assert.NoError(t, pool.Wait())
+
+	out := buf.String()
+	assert.Contains(t, out, "level=WARN msg=\"job timed out\" id="+id.Hex()+" name=foo attempt=1 timeout=10ms")
+}
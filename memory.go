@@ -0,0 +1,417 @@
+package mgojq
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// MemoryStore is a store that keeps all documents in memory. It implements the
+// subset of the MongoDB query and update language that is used by the package
+// and can be used to test code that uses a collection without a database.
+// Documents are stored in their BSON representation and therefore behave like
+// documents stored in MongoDB e.g. times have millisecond precision. Indexes
+// are not supported and therefore ended jobs are not removed automatically.
+type MemoryStore struct {
+	mutex  sync.Mutex
+	tables map[string]*memoryTable
+}
+
+// NewMemoryStore will create and return a new memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tables: make(map[string]*memoryTable),
+	}
+}
+
+// Table implements the Store interface.
+func (s *MemoryStore) Table(name string) Table {
+	// acquire mutex
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// get or create table
+	table, ok := s.tables[name]
+	if !ok {
+		table = &memoryTable{mutex: &s.mutex}
+		s.tables[name] = table
+	}
+
+	return table
+}
+
+type memoryTable struct {
+	mutex *sync.Mutex
+	docs  []bson.M
+}
+
+func (t *memoryTable) Insert(docs ...interface{}) error {
+	// acquire mutex
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// insert documents
+	for _, doc := range docs {
+		err := t.insert(doc)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *memoryTable) Find(query bson.M, opts FindOptions, result interface{}) error {
+	// acquire mutex
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// find documents
+	list, err := t.find(query, opts)
+	if err != nil {
+		return err
+	}
+
+	// project documents
+	values := make([]interface{}, 0, len(list))
+	for _, i := range list {
+		values = append(values, project(t.docs[i], opts.Select))
+	}
+
+	return decodeValue(values, result)
+}
+
+func (t *memoryTable) FindOne(query bson.M, opts FindOptions, result interface{}) error {
+	// acquire mutex
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// find document
+	opts.Limit = 1
+	list, err := t.find(query, opts)
+	if err != nil {
+		return err
+	} else if len(list) == 0 {
+		return mgo.ErrNotFound
+	}
+
+	return decodeValue(project(t.docs[list[0]], opts.Select), result)
+}
+
+func (t *memoryTable) Modify(query bson.M, opts FindOptions, update bson.M, result interface{}) error {
+	// acquire mutex
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// find document
+	opts.Limit = 1
+	list, err := t.find(query, opts)
+	if err != nil {
+		return err
+	} else if len(list) == 0 {
+		return mgo.ErrNotFound
+	}
+
+	// keep previous state
+	prev := t.docs[list[0]]
+
+	// update document
+	err = t.update(list[0], update)
+	if err != nil {
+		return err
+	}
+
+	return decodeValue(project(prev, opts.Select), result)
+}
+
+func (t *memoryTable) Update(query, update bson.M) error {
+	// acquire mutex
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// find document
+	list, err := t.find(query, FindOptions{Limit: 1})
+	if err != nil {
+		return err
+	} else if len(list) == 0 {
+		return mgo.ErrNotFound
+	}
+
+	return t.update(list[0], update)
+}
+
+func (t *memoryTable) Upsert(query, update bson.M) error {
+	// acquire mutex
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// find document
+	list, err := t.find(query, FindOptions{Limit: 1})
+	if err != nil {
+		return err
+	} else if len(list) > 0 {
+		return t.update(list[0], update)
+	}
+
+	// normalize query and update
+	q, err := normalize(query)
+	if err != nil {
+		return err
+	}
+	u, err := normalize(update)
+	if err != nil {
+		return err
+	}
+
+	// prepare document from equality conditions
+	doc := bson.M{}
+	for key, value := range q {
+		if key[0] == '$' || isOperatorDoc(value) {
+			continue
+		}
+		setPath(doc, key, copyValue(value))
+	}
+
+	// apply update
+	err = applyUpdate(doc, u, true)
+	if err != nil {
+		return err
+	}
+
+	return t.insert(doc)
+}
+
+func (t *memoryTable) UpdateAll(query, update bson.M) (int, error) {
+	// acquire mutex
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// find documents
+	list, err := t.find(query, FindOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	// update documents
+	for _, i := range list {
+		err = t.update(i, update)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(list), nil
+}
+
+func (t *memoryTable) RemoveAll(query bson.M) (int, error) {
+	// acquire mutex
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// find documents
+	list, err := t.find(query, FindOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	// mark documents
+	removed := make(map[int]bool, len(list))
+	for _, i := range list {
+		removed[i] = true
+	}
+
+	// keep other documents
+	docs := make([]bson.M, 0, len(t.docs)-len(list))
+	for i, doc := range t.docs {
+		if !removed[i] {
+			docs = append(docs, doc)
+		}
+	}
+	t.docs = docs
+
+	return len(list), nil
+}
+
+func (t *memoryTable) Distinct(query bson.M, key string, result interface{}) error {
+	// acquire mutex
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// find documents
+	list, err := t.find(query, FindOptions{})
+	if err != nil {
+		return err
+	}
+
+	// collect distinct values
+	values := make([]interface{}, 0)
+	add := func(value interface{}) {
+		for _, v := range values {
+			if compareValues(v, value) == 0 {
+				return
+			}
+		}
+		values = append(values, value)
+	}
+	for _, i := range list {
+		value, ok := lookup(t.docs[i], key)
+		if !ok {
+			continue
+		}
+		if arr, ok := value.([]interface{}); ok {
+			for _, item := range arr {
+				add(item)
+			}
+		} else {
+			add(value)
+		}
+	}
+
+	return decodeValue(values, result)
+}
+
+func (t *memoryTable) Bulk(ops []Operation) error {
+	// acquire mutex
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// run operations
+	var first error
+	for _, op := range ops {
+		var err error
+		if op.Insert != nil {
+			err = t.insert(op.Insert)
+		} else {
+			var list []int
+			list, err = t.find(op.Query, FindOptions{Limit: 1})
+			if err == nil && len(list) > 0 {
+				err = t.update(list[0], op.Update)
+			}
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+func (t *memoryTable) insert(value interface{}) error {
+	// normalize document
+	doc, err := normalize(value)
+	if err != nil {
+		return err
+	}
+
+	// ensure id
+	if _, ok := doc["_id"]; !ok {
+		doc["_id"] = bson.NewObjectId()
+	}
+
+	// check id
+	for _, d := range t.docs {
+		if compareValues(d["_id"], doc["_id"]) == 0 {
+			return &mgo.LastError{
+				Code: 11000,
+				Err:  "E11000 duplicate key error",
+			}
+		}
+	}
+
+	// add document
+	t.docs = append(t.docs, doc)
+
+	return nil
+}
+
+func (t *memoryTable) find(query bson.M, opts FindOptions) ([]int, error) {
+	// normalize query
+	q, err := normalize(query)
+	if err != nil {
+		return nil, err
+	}
+
+	// find matching documents
+	var list []int
+	for i, doc := range t.docs {
+		ok, err := matchQuery(doc, q)
+		if err != nil {
+			return nil, err
+		} else if ok {
+			list = append(list, i)
+		}
+	}
+
+	// sort documents
+	sortDocs(t.docs, list, opts.Sort)
+
+	// limit documents
+	if opts.Limit > 0 && len(list) > opts.Limit {
+		list = list[:opts.Limit]
+	}
+
+	return list, nil
+}
+
+func (t *memoryTable) update(i int, update bson.M) error {
+	// normalize update
+	u, err := normalize(update)
+	if err != nil {
+		return err
+	}
+
+	// update a copy to keep the document unchanged on errors
+	doc := copyValue(t.docs[i]).(bson.M)
+	err = applyUpdate(doc, u, false)
+	if err != nil {
+		return err
+	}
+
+	// replace document
+	t.docs[i] = doc
+
+	return nil
+}
+
+func normalize(value interface{}) (bson.M, error) {
+	// check value
+	if value == nil || (reflect.ValueOf(value).Kind() == reflect.Map && reflect.ValueOf(value).IsNil()) {
+		return bson.M{}, nil
+	}
+
+	// encode value
+	data, err := bson.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	// decode document
+	doc := bson.M{}
+	err = bson.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+func decodeValue(value interface{}, result interface{}) error {
+	// encode value
+	data, err := bson.Marshal(bson.M{"v": value})
+	if err != nil {
+		return err
+	}
+
+	// decode raw value
+	var raw struct {
+		V bson.Raw
+	}
+	err = bson.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	return raw.V.Unmarshal(result)
+}
@@ -0,0 +1,830 @@
+package mgojq
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+func matchQuery(doc, query bson.M) (bool, error) {
+	for key, cond := range query {
+		switch key {
+		case "$or", "$and", "$nor":
+			// get sub queries
+			list, ok := cond.([]interface{})
+			if !ok {
+				return false, fmt.Errorf("%s requires an array", key)
+			}
+
+			// count matching sub queries
+			n := 0
+			for _, item := range list {
+				sub, ok := item.(bson.M)
+				if !ok {
+					return false, fmt.Errorf("%s requires an array of documents", key)
+				}
+				ok, err := matchQuery(doc, sub)
+				if err != nil {
+					return false, err
+				} else if ok {
+					n++
+				}
+			}
+
+			// check result
+			if (key == "$or" && n == 0) || (key == "$and" && n < len(list)) || (key == "$nor" && n > 0) {
+				return false, nil
+			}
+		case "$expr":
+			// evaluate expression
+			value, err := evalExpr(doc, cond)
+			if err != nil {
+				return false, err
+			} else if !truthy(value) {
+				return false, nil
+			}
+		default:
+			// check operator
+			if key[0] == '$' {
+				return false, fmt.Errorf("unsupported query operator: %s", key)
+			}
+
+			// match field
+			value, found := lookup(doc, key)
+			ok, err := matchField(value, found, cond)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+	}
+
+	return true, nil
+}
+
+func matchField(value interface{}, found bool, cond interface{}) (bool, error) {
+	// check for plain value
+	ops, ok := cond.(bson.M)
+	if !ok || !isOperatorDoc(ops) {
+		return matchEach(value, func(v interface{}) bool {
+			return compareValues(v, cond) == 0
+		}), nil
+	}
+
+	// match all operators
+	for op, arg := range ops {
+		ok, err := matchOperator(value, found, op, arg)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func matchOperator(value interface{}, found bool, op string, arg interface{}) (bool, error) {
+	switch op {
+	case "$eq":
+		return matchField(value, found, arg)
+	case "$ne":
+		ok, err := matchField(value, found, arg)
+		return !ok, err
+	case "$in", "$nin":
+		// get values
+		list, ok := arg.([]interface{})
+		if !ok {
+			return false, fmt.Errorf("%s requires an array", op)
+		}
+
+		// check values
+		in := matchEach(value, func(v interface{}) bool {
+			for _, item := range list {
+				if compareValues(v, item) == 0 {
+					return true
+				}
+			}
+			return false
+		})
+
+		return in == (op == "$in"), nil
+	case "$lt", "$lte", "$gt", "$gte":
+		return matchEach(value, func(v interface{}) bool {
+			// only compare values of the same type
+			if typeRank(v) != typeRank(arg) {
+				return false
+			}
+
+			// compare values
+			res := compareValues(v, arg)
+			switch op {
+			case "$lt":
+				return res < 0
+			case "$lte":
+				return res <= 0
+			case "$gt":
+				return res > 0
+			default:
+				return res >= 0
+			}
+		}), nil
+	case "$exists":
+		return found == truthy(arg), nil
+	case "$not":
+		ok, err := matchField(value, found, arg)
+		return !ok, err
+	case "$regex":
+		// compile expression
+		re, err := regexp.Compile(fmt.Sprint(arg))
+		if err != nil {
+			return false, err
+		}
+
+		return matchEach(value, func(v interface{}) bool {
+			str, ok := v.(string)
+			return ok && re.MatchString(str)
+		}), nil
+	}
+
+	return false, fmt.Errorf("unsupported query operator: %s", op)
+}
+
+func matchEach(value interface{}, fn func(interface{}) bool) bool {
+	// check value
+	if fn(value) {
+		return true
+	}
+
+	// check array items
+	if arr, ok := value.([]interface{}); ok {
+		for _, item := range arr {
+			if fn(item) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func isOperatorDoc(value interface{}) bool {
+	// check document
+	doc, ok := value.(bson.M)
+	if !ok || len(doc) == 0 {
+		return false
+	}
+
+	// check keys
+	for key := range doc {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+
+	return true
+}
+
+func evalExpr(doc bson.M, expr interface{}) (interface{}, error) {
+	switch e := expr.(type) {
+	case string:
+		// resolve field references
+		if strings.HasPrefix(e, "$") {
+			value, _ := lookup(doc, e[1:])
+			return value, nil
+		}
+
+		return e, nil
+	case []interface{}:
+		// evaluate items
+		list := make([]interface{}, 0, len(e))
+		for _, item := range e {
+			value, err := evalExpr(doc, item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+
+		return list, nil
+	case bson.M:
+		// check operator
+		if len(e) != 1 || !isOperatorDoc(e) {
+			return e, nil
+		}
+
+		// get operator and arguments
+		var op string
+		var arg interface{}
+		for op, arg = range e {
+		}
+		value, err := evalExpr(doc, arg)
+		if err != nil {
+			return nil, err
+		}
+		args, ok := value.([]interface{})
+		if !ok {
+			args = []interface{}{value}
+		}
+
+		return evalOperator(op, args)
+	}
+
+	return expr, nil
+}
+
+func evalOperator(op string, args []interface{}) (interface{}, error) {
+	switch op {
+	case "$eq", "$ne", "$lt", "$lte", "$gt", "$gte":
+		// check arguments
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s requires two arguments", op)
+		}
+
+		// compare arguments
+		res := compareValues(args[0], args[1])
+		switch op {
+		case "$eq":
+			return res == 0, nil
+		case "$ne":
+			return res != 0, nil
+		case "$lt":
+			return res < 0, nil
+		case "$lte":
+			return res <= 0, nil
+		case "$gt":
+			return res > 0, nil
+		default:
+			return res >= 0, nil
+		}
+	case "$and":
+		for _, arg := range args {
+			if !truthy(arg) {
+				return false, nil
+			}
+		}
+		return true, nil
+	case "$or":
+		for _, arg := range args {
+			if truthy(arg) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$not":
+		return len(args) == 0 || !truthy(args[0]), nil
+	case "$ifNull":
+		for _, arg := range args {
+			if arg != nil {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	case "$max", "$min":
+		var res interface{}
+		for _, arg := range args {
+			if arg == nil {
+				continue
+			}
+			if res == nil || (op == "$max" && compareValues(arg, res) > 0) || (op == "$min" && compareValues(arg, res) < 0) {
+				res = arg
+			}
+		}
+		return res, nil
+	case "$add":
+		// add numbers and at most one date
+		var date *time.Time
+		sum := 0.0
+		for _, arg := range args {
+			if arg == nil {
+				return nil, nil
+			} else if t, ok := arg.(time.Time); ok && date == nil {
+				date = &t
+			} else if n, ok := toFloat(arg); ok {
+				sum += n
+			} else {
+				return nil, fmt.Errorf("$add only supports numbers and dates")
+			}
+		}
+		if date != nil {
+			return date.Add(time.Duration(sum * float64(time.Millisecond))), nil
+		}
+		return sum, nil
+	case "$subtract", "$multiply", "$divide":
+		// check arguments
+		if op != "$multiply" && len(args) != 2 {
+			return nil, fmt.Errorf("%s requires two arguments", op)
+		}
+		for _, arg := range args {
+			if arg == nil {
+				return nil, nil
+			}
+		}
+
+		// subtract dates
+		if op == "$subtract" {
+			if t, ok := args[0].(time.Time); ok {
+				if u, ok := args[1].(time.Time); ok {
+					return float64(t.Sub(u) / time.Millisecond), nil
+				} else if n, ok := toFloat(args[1]); ok {
+					return t.Add(-time.Duration(n * float64(time.Millisecond))), nil
+				}
+			}
+		}
+
+		// get numbers
+		nums := make([]float64, 0, len(args))
+		for _, arg := range args {
+			n, ok := toFloat(arg)
+			if !ok {
+				return nil, fmt.Errorf("%s only supports numbers", op)
+			}
+			nums = append(nums, n)
+		}
+
+		// calculate result
+		switch op {
+		case "$subtract":
+			return nums[0] - nums[1], nil
+		case "$divide":
+			return nums[0] / nums[1], nil
+		default:
+			res := 1.0
+			for _, n := range nums {
+				res *= n
+			}
+			return res, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported expression operator: %s", op)
+}
+
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	}
+
+	// check numbers
+	if n, ok := toFloat(value); ok {
+		return n != 0
+	}
+
+	return true
+}
+
+func typeRank(value interface{}) int {
+	switch value.(type) {
+	case nil:
+		return 1
+	case string:
+		return 3
+	case bson.M:
+		return 4
+	case []interface{}:
+		return 5
+	case []byte:
+		return 6
+	case bson.ObjectId:
+		return 7
+	case bool:
+		return 8
+	case time.Time:
+		return 9
+	}
+
+	// check numbers
+	if _, ok := toFloat(value); ok {
+		return 2
+	}
+
+	return 10
+}
+
+func compareValues(a, b interface{}) int {
+	// compare types
+	ra, rb := typeRank(a), typeRank(b)
+	if ra != rb {
+		return compareInts(ra, rb)
+	}
+
+	switch av := a.(type) {
+	case nil:
+		return 0
+	case string:
+		return strings.Compare(av, b.(string))
+	case bson.M:
+		return compareDocs(av, b.(bson.M))
+	case []interface{}:
+		bv := b.([]interface{})
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			if res := compareValues(av[i], bv[i]); res != 0 {
+				return res
+			}
+		}
+		return compareInts(len(av), len(bv))
+	case []byte:
+		return strings.Compare(string(av), string(b.([]byte)))
+	case bson.ObjectId:
+		return strings.Compare(string(av), string(b.(bson.ObjectId)))
+	case bool:
+		bv := b.(bool)
+		if av == bv {
+			return 0
+		} else if !av {
+			return -1
+		}
+		return 1
+	case time.Time:
+		bv := b.(time.Time)
+		if av.Before(bv) {
+			return -1
+		} else if av.After(bv) {
+			return 1
+		}
+		return 0
+	}
+
+	// compare numbers
+	if an, ok := toFloat(a); ok {
+		bn, _ := toFloat(b)
+		if an < bn {
+			return -1
+		} else if an > bn {
+			return 1
+		}
+		return 0
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func compareDocs(a, b bson.M) int {
+	// collect keys
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	// compare values
+	for _, key := range keys {
+		av, aok := a[key]
+		bv, bok := b[key]
+		if aok != bok {
+			if aok {
+				return 1
+			}
+			return -1
+		}
+		if res := compareValues(av, bv); res != 0 {
+			return res
+		}
+	}
+
+	return 0
+}
+
+func compareInts(a, b int) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func sortDocs(docs []bson.M, list []int, fields []string) {
+	// check fields
+	if len(fields) == 0 {
+		return
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		for _, field := range fields {
+			// get direction
+			desc := strings.HasPrefix(field, "-")
+			field = strings.TrimPrefix(field, "-")
+
+			// compare values
+			a, _ := lookup(docs[list[i]], field)
+			b, _ := lookup(docs[list[j]], field)
+			res := compareValues(a, b)
+			if desc {
+				res = -res
+			}
+			if res != 0 {
+				return res < 0
+			}
+		}
+
+		return false
+	})
+}
+
+func lookup(doc bson.M, path string) (interface{}, bool) {
+	var value interface{} = doc
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case bson.M:
+			item, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			value = item
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+func setPath(doc bson.M, path string, value interface{}) error {
+	_, err := setValue(doc, strings.Split(path, "."), value)
+	return err
+}
+
+func setValue(parent interface{}, keys []string, value interface{}) (interface{}, error) {
+	// get child
+	var child interface{}
+	switch p := parent.(type) {
+	case bson.M:
+		child = p[keys[0]]
+	case []interface{}:
+		// parse index
+		i, err := strconv.Atoi(keys[0])
+		if err != nil || i < 0 {
+			return nil, fmt.Errorf("cannot set %s in array", keys[0])
+		}
+
+		// pad array with nulls
+		for len(p) <= i {
+			p = append(p, nil)
+		}
+		parent = p
+		child = p[i]
+	default:
+		return nil, fmt.Errorf("cannot set %s in %T", keys[0], parent)
+	}
+
+	// set child value
+	if len(keys) > 1 {
+		if child == nil {
+			child = bson.M{}
+		}
+		var err error
+		value, err = setValue(child, keys[1:], value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// update parent
+	switch p := parent.(type) {
+	case bson.M:
+		p[keys[0]] = value
+	case []interface{}:
+		i, _ := strconv.Atoi(keys[0])
+		p[i] = value
+	}
+
+	return parent, nil
+}
+
+func unsetPath(doc bson.M, path string) {
+	// split path
+	keys := strings.Split(path, ".")
+
+	// get parent
+	parent := doc
+	if len(keys) > 1 {
+		value, ok := lookup(doc, strings.Join(keys[:len(keys)-1], "."))
+		if !ok {
+			return
+		}
+		parent, ok = value.(bson.M)
+		if !ok {
+			return
+		}
+	}
+
+	delete(parent, keys[len(keys)-1])
+}
+
+func project(doc bson.M, selector bson.M) bson.M {
+	// check selector
+	if len(selector) == 0 {
+		return doc
+	}
+
+	// check mode
+	include := false
+	for key, value := range selector {
+		if truthy(value) && (key != "_id" || len(selector) == 1) {
+			include = true
+		}
+	}
+
+	// exclude fields
+	if !include {
+		out := copyValue(doc).(bson.M)
+		for key := range selector {
+			unsetPath(out, key)
+		}
+		return out
+	}
+
+	// include fields
+	out := bson.M{}
+	if value, ok := selector["_id"]; !ok || truthy(value) {
+		out["_id"] = doc["_id"]
+	}
+	for key, value := range selector {
+		if key == "_id" || !truthy(value) {
+			continue
+		}
+		if v, ok := lookup(doc, key); ok {
+			_ = setPath(out, key, copyValue(v))
+		}
+	}
+
+	return out
+}
+
+func applyUpdate(doc, update bson.M, insert bool) error {
+	// check for replacement
+	if !isOperatorDoc(update) {
+		id := doc["_id"]
+		for key := range doc {
+			delete(doc, key)
+		}
+		for key, value := range update {
+			doc[key] = copyValue(value)
+		}
+		if id != nil {
+			doc["_id"] = id
+		}
+		return nil
+	}
+
+	for op, arg := range update {
+		// get fields
+		fields, ok := arg.(bson.M)
+		if !ok {
+			return fmt.Errorf("%s requires a document", op)
+		}
+
+		for path, value := range fields {
+			var err error
+			switch op {
+			case "$set":
+				err = setPath(doc, path, copyValue(value))
+			case "$setOnInsert":
+				if insert {
+					err = setPath(doc, path, copyValue(value))
+				}
+			case "$unset":
+				unsetPath(doc, path)
+			case "$inc":
+				current, _ := lookup(doc, path)
+				err = setPath(doc, path, addNumbers(current, value))
+			case "$push", "$addToSet":
+				err = pushValues(doc, path, value, op == "$addToSet")
+			case "$pull":
+				current, _ := lookup(doc, path)
+				if arr, ok := current.([]interface{}); ok {
+					list := make([]interface{}, 0, len(arr))
+					for _, item := range arr {
+						if compareValues(item, value) != 0 {
+							list = append(list, item)
+						}
+					}
+					err = setPath(doc, path, list)
+				}
+			default:
+				return fmt.Errorf("unsupported update operator: %s", op)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func pushValues(doc bson.M, path string, value interface{}, unique bool) error {
+	// get current array
+	current, _ := lookup(doc, path)
+	arr, _ := current.([]interface{})
+	arr = append([]interface{}{}, arr...)
+
+	// get items and modifiers
+	items := []interface{}{value}
+	position := len(arr)
+	slice, sliced := 0, false
+	if mod, ok := value.(bson.M); ok && isOperatorDoc(mod) {
+		each, ok := mod["$each"].([]interface{})
+		if !ok {
+			return fmt.Errorf("$each requires an array")
+		}
+		items = each
+		if pos, ok := toInt(mod["$position"]); ok && int(pos) < position {
+			position = int(pos)
+		}
+		if n, ok := toInt(mod["$slice"]); ok {
+			slice, sliced = int(n), true
+		}
+	}
+
+	// remove existing items
+	if unique {
+		list := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			if !matchEach(arr, func(v interface{}) bool {
+				return compareValues(v, item) == 0
+			}) {
+				list = append(list, item)
+			}
+		}
+		items = list
+	}
+
+	// insert items
+	list := make([]interface{}, 0, len(arr)+len(items))
+	list = append(list, arr[:position]...)
+	for _, item := range items {
+		list = append(list, copyValue(item))
+	}
+	list = append(list, arr[position:]...)
+
+	// slice array
+	if sliced {
+		if slice >= 0 && slice < len(list) {
+			list = list[:slice]
+		} else if slice < 0 && -slice < len(list) {
+			list = list[len(list)+slice:]
+		}
+	}
+
+	return setPath(doc, path, list)
+}
+
+func addNumbers(a, b interface{}) interface{} {
+	// use integers if possible
+	ai, aok := toInt(a)
+	bi, bok := toInt(b)
+	if (aok || a == nil) && bok {
+		sum := ai + bi
+		if _, ok := a.(int64); ok {
+			return sum
+		} else if _, ok := b.(int64); ok {
+			return sum
+		}
+		return int(sum)
+	}
+
+	// otherwise use floats
+	af, _ := toFloat(a)
+	bf, _ := toFloat(b)
+
+	return af + bf
+}
+
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.M:
+		doc := make(bson.M, len(v))
+		for key, item := range v {
+			doc[key] = copyValue(item)
+		}
+		return doc
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = copyValue(item)
+		}
+		return list
+	case []byte:
+		return append([]byte{}, v...)
+	}
+
+	return value
+}
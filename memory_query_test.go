@@ -0,0 +1,178 @@
+package mgojq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchQuery(t *testing.T) {
+	now := time.Now()
+
+	doc, err := normalize(bson.M{
+		"name":    "foo",
+		"count":   3,
+		"tags":    []interface{}{"a", "b"},
+		"params":  bson.M{"key": 1.5},
+		"started": now,
+		"timeout": int64(100),
+	})
+	assert.NoError(t, err)
+
+	table := []struct {
+		query bson.M
+		match bool
+	}{
+		{bson.M{}, true},
+		{bson.M{"name": "foo"}, true},
+		{bson.M{"name": "bar"}, false},
+		{bson.M{"count": 3.0}, true},
+		{bson.M{"tags": "b"}, true},
+		{bson.M{"tags": "c"}, false},
+		{bson.M{"params.key": 1.5}, true},
+		{bson.M{"missing": nil}, true},
+		{bson.M{"name": bson.M{"$ne": "bar"}}, true},
+		{bson.M{"name": bson.M{"$in": []string{"foo", "bar"}}}, true},
+		{bson.M{"name": bson.M{"$nin": []string{"foo", "bar"}}}, false},
+		{bson.M{"count": bson.M{"$gt": 2, "$lte": 3}}, true},
+		{bson.M{"count": bson.M{"$lt": 3}}, false},
+		{bson.M{"count": bson.M{"$lt": "z"}}, false},
+		{bson.M{"missing": bson.M{"$lte": now}}, false},
+		{bson.M{"missing": bson.M{"$not": bson.M{"$lte": now}}}, true},
+		{bson.M{"started": bson.M{"$lte": now}}, true},
+		{bson.M{"missing": bson.M{"$exists": false}}, true},
+		{bson.M{"name": bson.M{"$exists": false}}, false},
+		{bson.M{"name": bson.M{"$regex": "^f"}}, true},
+		{bson.M{"$or": []bson.M{{"name": "bar"}, {"count": 3}}}, true},
+		{bson.M{"$and": []bson.M{{"name": "foo"}, {"count": 4}}}, false},
+		{bson.M{"$nor": []bson.M{{"name": "bar"}}}, true},
+		{bson.M{"$expr": bson.M{"$gt": []interface{}{"$count", 2}}}, true},
+		{bson.M{"$expr": bson.M{"$lte": []interface{}{
+			bson.M{"$add": []interface{}{
+				bson.M{"$max": []interface{}{"$started", "$missing"}},
+				bson.M{"$ifNull": []interface{}{"$timeout", 1000}},
+			}},
+			now,
+		}}}, false},
+		{bson.M{"$expr": bson.M{"$lte": []interface{}{
+			bson.M{"$add": []interface{}{"$started", bson.M{"$ifNull": []interface{}{"$missing", 0}}}},
+			now,
+		}}}, true},
+	}
+
+	for i, item := range table {
+		q, err := normalize(item.query)
+		assert.NoError(t, err)
+
+		match, err := matchQuery(doc, q)
+		assert.NoError(t, err)
+		assert.Equal(t, item.match, match, i)
+	}
+
+	_, err = matchQuery(doc, bson.M{"name": bson.M{"$foo": 1}})
+	assert.Error(t, err)
+}
+
+func TestApplyUpdate(t *testing.T) {
+	doc := bson.M{
+		"count":   1,
+		"history": []interface{}{bson.M{"status": "a"}},
+		"tags":    []interface{}{"a"},
+	}
+
+	err := applyUpdate(doc, bson.M{
+		"$set": bson.M{
+			"history.0.status": "b",
+			"history.1.status": "c",
+			"params.key":       "value",
+		},
+		"$inc": bson.M{
+			"count": 2,
+			"other": 1.5,
+		},
+		"$addToSet": bson.M{
+			"tags": "a",
+		},
+	}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{
+		"count":   3,
+		"other":   1.5,
+		"history": []interface{}{bson.M{"status": "b"}, bson.M{"status": "c"}},
+		"params":  bson.M{"key": "value"},
+		"tags":    []interface{}{"a"},
+	}, doc)
+
+	err = applyUpdate(doc, bson.M{
+		"$push": bson.M{
+			"history": bson.M{
+				"$each":     []interface{}{bson.M{"status": "d"}},
+				"$position": 0,
+				"$slice":    2,
+			},
+			"tags": "b",
+		},
+		"$unset": bson.M{
+			"params.key": "",
+			"other":      "",
+		},
+		"$setOnInsert": bson.M{
+			"created": true,
+		},
+	}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{
+		"count":   3,
+		"history": []interface{}{bson.M{"status": "d"}, bson.M{"status": "b"}},
+		"params":  bson.M{},
+		"tags":    []interface{}{"a", "b"},
+	}, doc)
+
+	err = applyUpdate(doc, bson.M{
+		"$set": bson.M{"count.foo": 1},
+	}, false)
+	assert.Error(t, err)
+}
+
+func TestSortDocs(t *testing.T) {
+	docs := []bson.M{
+		{"_id": 1, "priority": 0},
+		{"_id": 2, "priority": 2},
+		{"_id": 3},
+		{"_id": 4, "priority": 2},
+		{"_id": 5, "priority": "high"},
+	}
+
+	list := []int{0, 1, 2, 3, 4}
+	sortDocs(docs, list, []string{"-priority", "_id"})
+	assert.Equal(t, []int{4, 1, 3, 0, 2}, list)
+}
+
+func TestProject(t *testing.T) {
+	doc := bson.M{
+		"_id":  1,
+		"name": "foo",
+		"encrypted": bson.M{
+			"params": "a",
+			"result": "b",
+		},
+	}
+
+	assert.Equal(t, doc, project(doc, nil))
+	assert.Equal(t, bson.M{
+		"_id":  1,
+		"name": "foo",
+	}, project(doc, bson.M{"name": 1}))
+	assert.Equal(t, bson.M{
+		"_id": 1,
+	}, project(doc, bson.M{"_id": 1}))
+	assert.Equal(t, bson.M{
+		"encrypted": bson.M{"params": "a"},
+	}, project(doc, bson.M{"_id": 0, "encrypted.params": 1}))
+	assert.Equal(t, bson.M{
+		"_id":       1,
+		"encrypted": bson.M{"result": "b"},
+	}, project(doc, bson.M{"name": 0, "encrypted.params": 0}))
+}
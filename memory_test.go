@@ -0,0 +1,118 @@
+package mgojq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestMemoryStoreIsolation(t *testing.T) {
+	table := NewMemoryStore().Table("")
+
+	params := bson.M{"foo": "bar"}
+	err := table.Insert(bson.M{"_id": 1, "params": params})
+	assert.NoError(t, err)
+
+	params["foo"] = "baz"
+
+	var doc bson.M
+	err = table.FindOne(nil, FindOptions{}, &doc)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"foo": "bar"}, doc["params"])
+
+	doc["params"].(bson.M)["foo"] = "baz"
+
+	doc = nil
+	err = table.FindOne(nil, FindOptions{}, &doc)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"foo": "bar"}, doc["params"])
+}
+
+func TestMemoryStoreUnsupported(t *testing.T) {
+	jqc := New(NewMemoryStore())
+
+	assert.NoError(t, jqc.EnsureIndexes(time.Hour))
+	assert.NoError(t, jqc.EnsureValidator())
+
+	_, err := jqc.Stats([]string{"foo"}, time.Hour)
+	assert.Equal(t, ErrUnsupported, err)
+
+	n, err := jqc.Cleanup()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	assert.Panics(t, func() {
+		jqc.SetOffload(1024)
+	})
+}
+
+func TestMemoryStoreQueue(t *testing.T) {
+	jqc := New(NewMemoryStore())
+
+	id1, err := jqc.EnqueueWith("foo", bson.M{"n": 1}, Options{
+		Timeout: 50 * time.Millisecond,
+	})
+	assert.NoError(t, err)
+
+	id2, err := jqc.EnqueueWith("foo", bson.M{"n": 2}, Options{
+		Priority: 1,
+	})
+	assert.NoError(t, err)
+
+	id3, err := jqc.EnqueueWith("foo", bson.M{"n": 3}, Options{
+		Delay: 50 * time.Millisecond,
+	})
+	assert.NoError(t, err)
+
+	_, err = jqc.EnqueueWith("foo", bson.M{"n": 4}, Options{
+		Expires: time.Now().Add(-time.Minute),
+	})
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id2, job.ID)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id1, job.ID)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+
+	time.Sleep(60 * time.Millisecond)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id1, job.ID)
+	assert.Equal(t, 2, job.Attempts)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id3, job.ID)
+
+	err = jqc.Fail(id3, "some error", time.Hour)
+	assert.NoError(t, err)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+
+	n, err := jqc.Sweep(time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	job, err = jqc.Fetch(id3)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusFailed, job.Status)
+	assert.Equal(t, "some error", job.Error)
+	assert.Equal(t, StatusFailed, job.History[0].Status)
+	assert.Equal(t, "some error", job.History[0].Error)
+}
@@ -0,0 +1,206 @@
+// Package metrics provides a Prometheus collector for mgojq collections and
+// pools.
+package metrics
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/256dpi/mgojq"
+)
+
+// A Collector collects metrics about a collection and its pools. It implements
+// the mgojq.Observer and prometheus.Collector interfaces.
+type Collector struct {
+	coll  *mgojq.Collection
+	names []string
+
+	busyWorkers  int64
+	totalWorkers int64
+
+	operations *prometheus.CounterVec
+	durations  *prometheus.HistogramVec
+	latencies  *prometheus.HistogramVec
+	executions *prometheus.HistogramVec
+	busy       prometheus.GaugeFunc
+	idle       prometheus.GaugeFunc
+
+	depth  *prometheus.Desc
+	oldest *prometheus.Desc
+}
+
+// NewCollector will create and return a new collector for the specified
+// collection. The queue depth and oldest pending job age of the specified job
+// names are queried from the collection when metrics are collected. They are
+// omitted if the store of the collection does not support statistics. The
+// collector observes the collection and must be registered with a Prometheus
+// registry to expose the metrics.
+func NewCollector(coll *mgojq.Collection, names []string) *Collector {
+	// create collector
+	c := &Collector{
+		coll:  coll,
+		names: names,
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "mgojq",
+			Name:      "operations_total",
+			Help:      "The number of performed operations by job name.",
+		}, []string{"operation", "name"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "mgojq",
+			Name:      "job_duration_seconds",
+			Help:      "The time between dequeueing and ending jobs by job name and status.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+		}, []string{"name", "status"}),
+		latencies: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "mgojq",
+			Name:      "dequeue_latency_seconds",
+			Help:      "The time it took to dequeue jobs by job name.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"name"}),
+		executions: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "mgojq",
+			Name:      "worker_duration_seconds",
+			Help:      "The time workers took to process jobs by job name and result.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+		}, []string{"name", "result"}),
+		depth: prometheus.NewDesc(
+			"mgojq_queue_depth",
+			"The number of jobs by job name and status.",
+			[]string{"name", "status"}, nil,
+		),
+		oldest: prometheus.NewDesc(
+			"mgojq_oldest_pending_seconds",
+			"The time the oldest due job has been waiting by job name.",
+			[]string{"name"}, nil,
+		),
+	}
+
+	// create worker gauges
+	c.busy = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "mgojq",
+		Name:      "pool_busy_workers",
+		Help:      "The number of workers that are currently processing a job.",
+	}, func() float64 {
+		return float64(atomic.LoadInt64(&c.busyWorkers))
+	})
+	c.idle = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "mgojq",
+		Name:      "pool_idle_workers",
+		Help:      "The number of workers that are currently waiting for a job.",
+	}, func() float64 {
+		return float64(atomic.LoadInt64(&c.totalWorkers) - atomic.LoadInt64(&c.busyWorkers))
+	})
+
+	// observe collection
+	coll.Observe(c)
+
+	return c
+}
+
+// Instrument will observe the specified pool to track busy and idle workers.
+func (c *Collector) Instrument(pool *mgojq.Pool) {
+	pool.Observe(c)
+	atomic.AddInt64(&c.totalWorkers, int64(pool.Size()))
+}
+
+// Middleware returns a worker middleware that tracks the time workers take to
+// process jobs and whether they returned an error.
+func (c *Collector) Middleware() mgojq.Middleware {
+	return func(next mgojq.Worker) mgojq.Worker {
+		return func(coll *mgojq.Collection, j *mgojq.Job, quit <-chan struct{}) error {
+			// call worker
+			start := time.Now()
+			err := next(coll, j, quit)
+
+			// get result
+			result := "ok"
+			if err != nil {
+				result = "error"
+			}
+
+			// observe duration
+			c.executions.WithLabelValues(j.Name, result).Observe(time.Since(start).Seconds())
+
+			return err
+		}
+	}
+}
+
+// Observe implements the mgojq.Observer interface.
+func (c *Collector) Observe(e mgojq.Event) {
+	switch e.Type {
+	case mgojq.EventEnqueued:
+		c.operations.WithLabelValues("enqueue", e.Name).Inc()
+	case mgojq.EventDequeued:
+		c.operations.WithLabelValues("dequeue", e.Name).Inc()
+		c.latencies.WithLabelValues(e.Name).Observe(e.Duration.Seconds())
+	case mgojq.EventCompleted:
+		c.operations.WithLabelValues("complete", e.Name).Inc()
+		c.durations.WithLabelValues(e.Name, mgojq.StatusCompleted).Observe(e.Duration.Seconds())
+	case mgojq.EventFailed:
+		c.operations.WithLabelValues("fail", e.Name).Inc()
+		c.durations.WithLabelValues(e.Name, mgojq.StatusFailed).Observe(e.Duration.Seconds())
+	case mgojq.EventCancelled:
+		c.operations.WithLabelValues("cancel", e.Name).Inc()
+		c.durations.WithLabelValues(e.Name, mgojq.StatusCancelled).Observe(e.Duration.Seconds())
+	case mgojq.EventProcessing:
+		atomic.AddInt64(&c.busyWorkers, 1)
+	case mgojq.EventProcessed:
+		atomic.AddInt64(&c.busyWorkers, -1)
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.operations.Describe(ch)
+	c.durations.Describe(ch)
+	c.latencies.Describe(ch)
+	c.executions.Describe(ch)
+	c.busy.Describe(ch)
+	c.idle.Describe(ch)
+	ch <- c.depth
+	ch <- c.oldest
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	// collect tracked metrics
+	c.operations.Collect(ch)
+	c.durations.Collect(ch)
+	c.latencies.Collect(ch)
+	c.executions.Collect(ch)
+	c.busy.Collect(ch)
+	c.idle.Collect(ch)
+
+	// check names
+	if len(c.names) == 0 {
+		return
+	}
+
+	// get stats
+	stats, err := c.coll.Stats(c.names, time.Minute)
+	if errors.Is(err, mgojq.ErrUnsupported) {
+		return
+	} else if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.depth, err)
+		return
+	}
+
+	// collect stats
+	for _, name := range c.names {
+		for _, status := range []string{
+			mgojq.StatusEnqueued,
+			mgojq.StatusDequeued,
+			mgojq.StatusCompleted,
+			mgojq.StatusFailed,
+			mgojq.StatusCancelled,
+			mgojq.StatusExpired,
+		} {
+			ch <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue, float64(stats[name].Counts[status]), name, status)
+		}
+		ch <- prometheus.MustNewConstMetric(c.oldest, prometheus.GaugeValue, stats[name].OldestPending.Seconds(), name)
+	}
+}
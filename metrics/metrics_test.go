@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/256dpi/mgojq"
+)
+
+var db *mgo.Database
+
+func init() {
+	// create session
+	sess, err := mgo.DialWithTimeout("mongodb://localhost/test-mgojq-metrics", time.Second)
+	if err != nil {
+		// skip tests
+		return
+	}
+
+	// save db reference
+	db = sess.DB("")
+
+	// drop database
+	err = db.DropDatabase()
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestCollector(t *testing.T) {
+	if db == nil {
+		t.Skip("database not available")
+	}
+
+	jqc := mgojq.Wrap(db.C("test-collector"))
+
+	collector := NewCollector(jqc, []string{"foo"})
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	pool := mgojq.NewPool(2, 0, time.Hour)
+	pool.Register("foo", func(c *mgojq.Collection, j *mgojq.Job, quit <-chan struct{}) error {
+		return c.Complete(j.ID, nil)
+	})
+	pool.Use(collector.Middleware())
+	collector.Instrument(pool)
+
+	_, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	_, err = jqc.Enqueue("foo", nil, time.Hour)
+	assert.NoError(t, err)
+
+	pool.Start(jqc)
+	time.Sleep(20 * time.Millisecond)
+	pool.Close()
+	assert.NoError(t, pool.Wait())
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(collector.operations.WithLabelValues("enqueue", "foo")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.operations.WithLabelValues("dequeue", "foo")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.operations.WithLabelValues("complete", "foo")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(collector.busy))
+	assert.Equal(t, 2.0, testutil.ToFloat64(collector.idle))
+	assert.Equal(t, 1, testutil.CollectAndCount(collector.executions))
+
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+
+	depth := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() == "mgojq_queue_depth" {
+			for _, metric := range family.GetMetric() {
+				depth[metric.GetLabel()[1].GetValue()] = metric.GetGauge().GetValue()
+			}
+		}
+	}
+
+	assert.Equal(t, 1.0, depth[mgojq.StatusEnqueued])
+	assert.Equal(t, 1.0, depth[mgojq.StatusCompleted])
+}
+
+func TestCollectorMemoryStore(t *testing.T) {
+	jqc := mgojq.New(mgojq.NewMemoryStore())
+
+	collector := NewCollector(jqc, []string{"foo"})
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	_, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+
+	names := map[string]bool{}
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+
+	assert.True(t, names["mgojq_operations_total"])
+	assert.False(t, names["mgojq_queue_depth"])
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.operations.WithLabelValues("enqueue", "foo")))
+}
@@ -0,0 +1,83 @@
+package mgojq
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Logging returns a middleware that logs the start and end of every job using
+// the specified logger.
+func Logging(logger *slog.Logger) Middleware {
+	return func(next Worker) Worker {
+		return func(c *Collection, j *Job, quit <-chan struct{}) error {
+			// get attributes
+			attrs := []any{
+				slog.String("id", j.ID.Hex()),
+				slog.String("name", j.Name),
+				slog.Int("attempt", j.Attempts),
+			}
+
+			// log start
+			logger.InfoContext(j.Context(), "processing job", attrs...)
+
+			// call worker
+			start := time.Now()
+			err := next(c, j, quit)
+			attrs = append(attrs, slog.Duration("duration", time.Since(start)))
+
+			// log end
+			if err != nil {
+				logger.ErrorContext(j.Context(), "job processing failed", append(attrs, slog.String("error", err.Error()))...)
+			} else {
+				logger.InfoContext(j.Context(), "processed job", attrs...)
+			}
+
+			return err
+		}
+	}
+}
+
+// Timeout returns a middleware that closes the quit channel passed to the
+// worker once the specified timeout has passed.
+func Timeout(timeout time.Duration) Middleware {
+	return func(next Worker) Worker {
+		return func(c *Collection, j *Job, quit <-chan struct{}) error {
+			// prepare timer
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+
+			// close channel on quit or timeout
+			ch := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				select {
+				case <-quit:
+				case <-timer.C:
+				case <-done:
+				}
+				close(ch)
+			}()
+			defer close(done)
+
+			return next(c, j, ch)
+		}
+	}
+}
+
+// Recovery returns a middleware that recovers panics raised by the worker and
+// fails the job with the panic as the error. The pool continues to run unless
+// the job cannot be failed.
+func Recovery() Middleware {
+	return func(next Worker) Worker {
+		return func(c *Collection, j *Job, quit <-chan struct{}) (err error) {
+			defer func() {
+				if val := recover(); val != nil {
+					err = c.Fail(j.ID, fmt.Sprintf("panic: %v", val), 0)
+				}
+			}()
+
+			return next(c, j, quit)
+		}
+	}
+}
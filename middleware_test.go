@@ -0,0 +1,70 @@
+package mgojq
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogging(t *testing.T) {
+	jqc := newCollection("test-logging")
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	worker := Logging(logger)(func(c *Collection, j *Job, quit <-chan struct{}) error {
+		return errors.New("some error")
+	})
+
+	err = worker(jqc, job, nil)
+	assert.Equal(t, "some error", err.Error())
+
+	out := buf.String()
+	assert.Contains(t, out, "msg=\"processing job\" id="+id.Hex()+" name=foo attempt=1")
+	assert.Contains(t, out, "msg=\"job processing failed\" id="+id.Hex()+" name=foo attempt=1")
+	assert.Contains(t, out, "error=\"some error\"")
+}
+
+func TestTimeout(t *testing.T) {
+	worker := Timeout(10 * time.Millisecond)(func(c *Collection, j *Job, quit <-chan struct{}) error {
+		select {
+		case <-quit:
+			return nil
+		case <-time.After(time.Second):
+			return errors.New("not stopped")
+		}
+	})
+
+	assert.NoError(t, worker(nil, &Job{}, make(chan struct{})))
+}
+
+func TestRecovery(t *testing.T) {
+	jqc := newCollection("test-recovery")
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+
+	worker := Recovery()(func(c *Collection, j *Job, quit <-chan struct{}) error {
+		panic("some panic")
+	})
+
+	assert.NoError(t, worker(jqc, job, nil))
+
+	job, err = jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusFailed, job.Status)
+	assert.Equal(t, "panic: some panic", job.Error)
+}
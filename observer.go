@@ -0,0 +1,147 @@
+package mgojq
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// The available event types.
+const (
+	EventEnqueued   = "enqueued"
+	EventDequeued   = "dequeued"
+	EventCompleted  = "completed"
+	EventFailed     = "failed"
+	EventCancelled  = "cancelled"
+	EventReclaimed  = "reclaimed"
+	EventProcessing = "processing"
+	EventProcessed  = "processed"
+)
+
+// An Event describes an operation that has been performed on a job.
+type Event struct {
+	// The type of the event.
+	Type string
+
+	// The id of the job.
+	Job bson.ObjectId
+
+	// The name of the job.
+	Name string
+
+	// The attempt of the job.
+	Attempt int
+
+	// The duration of the operation. For dequeued events it is the time it
+	// took to dequeue the job. For completed, failed and cancelled events it
+	// is the time since the job has been dequeued. For processed events it is
+	// the time the worker took to process the job.
+	Duration time.Duration
+}
+
+// An Observer receives events from a collection or pool. Observers are called
+// synchronously and should therefore return quickly. Panics raised by
+// observers are recovered and do not affect the observed operation.
+type Observer interface {
+	Observe(Event)
+}
+
+// The ObserverFunc type is an adapter to allow the use of ordinary functions
+// as observers.
+type ObserverFunc func(Event)
+
+// Observe calls f(e).
+func (f ObserverFunc) Observe(e Event) {
+	f(e)
+}
+
+// Hooks is an observer that invokes the configured callbacks for the
+// corresponding events. Errors returned and panics raised by the callbacks are
+// reported to OnError and never affect the observed operation.
+type Hooks struct {
+	// Called when a job has been enqueued by Enqueue or Bulk.Run.
+	OnEnqueued func(Event) error
+
+	// Called when a job has been dequeued.
+	OnDequeued func(Event) error
+
+	// Called when a job has been completed by Complete or Bulk.Run.
+	OnCompleted func(Event) error
+
+	// Called when a job has been failed by Fail or Bulk.Run.
+	OnFailed func(Event) error
+
+	// Called when a job has been cancelled by Cancel or Bulk.Run.
+	OnCancelled func(Event) error
+
+	// Called when a job has been reclaimed by Dequeue after its timeout has
+	// passed or by Reclaim after its worker has gone stale.
+	OnReclaimed func(Event) error
+
+	// Called with errors returned by the callbacks.
+	OnError func(error)
+}
+
+// Observe implements the Observer interface.
+func (h *Hooks) Observe(e Event) {
+	// get callback
+	var fn func(Event) error
+	switch e.Type {
+	case EventEnqueued:
+		fn = h.OnEnqueued
+	case EventDequeued:
+		fn = h.OnDequeued
+	case EventCompleted:
+		fn = h.OnCompleted
+	case EventFailed:
+		fn = h.OnFailed
+	case EventCancelled:
+		fn = h.OnCancelled
+	case EventReclaimed:
+		fn = h.OnReclaimed
+	}
+
+	// check callback
+	if fn == nil {
+		return
+	}
+
+	// call callback and report errors and panics
+	err := func() (err error) {
+		defer func() {
+			if val := recover(); val != nil {
+				err = fmt.Errorf("%s hook panicked: %v", e.Type, val)
+			}
+		}()
+
+		return fn(e)
+	}()
+	if err != nil && h.OnError != nil {
+		h.OnError(err)
+	}
+}
+
+// Observe will add the specified observer that receives enqueued, dequeued,
+// completed, failed, cancelled and reclaimed events. Observers must be added
+// before the collection is used.
+func (c *Collection) Observe(observer Observer) {
+	c.observers = append(c.observers, observer)
+}
+
+func (c *Collection) notify(event Event) {
+	logEvent(c.logger, event)
+	notify(c.observers, event)
+}
+
+func notify(observers []Observer, event Event) {
+	for _, observer := range observers {
+		func() {
+			defer func() {
+				_ = recover()
+			}()
+
+			observer.Observe(event)
+		}()
+	}
+}
@@ -0,0 +1,116 @@
+package mgojq
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionObserve(t *testing.T) {
+	jqc := newCollection("test-coll-observe")
+
+	var events []Event
+	jqc.Observe(ObserverFunc(func(e Event) {
+		events = append(events, e)
+	}))
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo"}, 0)
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+
+	job, err = jqc.Dequeue([]string{"foo"}, 0)
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+
+	err = jqc.Fail(id, "some error", 0)
+	assert.NoError(t, err)
+
+	var types []string
+	for _, event := range events {
+		assert.Equal(t, id, event.Job)
+		assert.Equal(t, "foo", event.Name)
+		types = append(types, event.Type)
+	}
+
+	assert.Equal(t, []string{
+		EventEnqueued,
+		EventDequeued,
+		EventReclaimed,
+		EventDequeued,
+		EventFailed,
+	}, types)
+	assert.Equal(t, 1, events[2].Attempt)
+	assert.Equal(t, 2, events[3].Attempt)
+	assert.Equal(t, 2, events[4].Attempt)
+}
+
+func TestCollectionObserveBulk(t *testing.T) {
+	jqc := newCollection("test-coll-observe-bulk")
+
+	var events []Event
+	jqc.Observe(ObserverFunc(func(e Event) {
+		events = append(events, e)
+	}))
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	bulk := jqc.Bulk()
+	id2 := bulk.Enqueue("bar", nil, 0)
+	bulk.Cancel(id, "some reason")
+	err = bulk.Run()
+	assert.NoError(t, err)
+
+	assert.Len(t, events, 3)
+	assert.Equal(t, Event{Type: EventEnqueued, Job: id2, Name: "bar"}, events[1])
+	assert.Equal(t, Event{Type: EventCancelled, Job: id, Name: "foo"}, events[2])
+}
+
+func TestHooks(t *testing.T) {
+	jqc := newCollection("test-hooks")
+
+	var completed []Event
+	var errs []error
+
+	jqc.Observe(&Hooks{
+		OnEnqueued: func(Event) error {
+			return errors.New("some error")
+		},
+		OnDequeued: func(Event) error {
+			panic("some panic")
+		},
+		OnCompleted: func(e Event) error {
+			completed = append(completed, e)
+			return nil
+		},
+		OnError: func(err error) {
+			errs = append(errs, err)
+		},
+	})
+
+	jqc.Observe(ObserverFunc(func(Event) {
+		panic("some panic")
+	}))
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+
+	err = jqc.Complete(id, nil)
+	assert.NoError(t, err)
+
+	assert.Len(t, completed, 1)
+	assert.Equal(t, id, completed[0].Job)
+	assert.Equal(t, []error{
+		errors.New("some error"),
+		errors.New("dequeued hook panicked: some panic"),
+	}, errs)
+}
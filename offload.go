@@ -0,0 +1,430 @@
+package mgojq
+
+import (
+	"io"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// Refs holds the ids of the params and result of a job that are stored in
+// GridFS. See SetOffload for details.
+type Refs struct {
+	Params bson.ObjectId `bson:",omitempty"`
+	Result bson.ObjectId `bson:",omitempty"`
+}
+
+type payload struct {
+	Doc        bson.M      `bson:",omitempty"`
+	Encrypted  *Ciphertext `bson:",omitempty"`
+	Compressed *Blob       `bson:",omitempty"`
+}
+
+// SetOffload will enable the offloading of params and results whose encoded
+// size exceeds the specified threshold in bytes. Offloaded values are stored
+// in the "<collection>.payloads" GridFS bucket, referenced by the job and
+// loaded transparently by Dequeue, Fetch, List and Owned. Offloaded params
+// cannot be queried. Payloads of jobs deleted by DeleteWhere are removed
+// immediately, payloads of jobs removed by the TTL index are removed by
+// Cleanup. Offloading must be enabled before the collection is used and is
+// only available with a MongoStore.
+func (c *Collection) SetOffload(threshold int) {
+	// check store
+	if c.coll == nil {
+		panic("offloading requires a mongo store")
+	}
+
+	c.threshold = threshold
+}
+
+// Cleanup will remove the offloaded payloads of jobs that have been removed by
+// the TTL index created by EnsureIndexes. Only payloads of jobs that have ended
+// before the removal period of the index are checked. It returns the number of
+// removed payloads.
+func (c *Collection) Cleanup() (int, error) {
+	// check store
+	if c.coll == nil {
+		return 0, nil
+	}
+
+	// get removal period
+	removeAfter, err := c.removeAfter()
+	if err != nil || removeAfter <= 0 {
+		return 0, err
+	}
+
+	// find payloads of removed jobs
+	iter := c.payloads().Find(bson.M{
+		"metadata.ended": bson.M{
+			"$lte": time.Now().Add(-removeAfter),
+		},
+	}).Select(bson.M{
+		"metadata": 1,
+	}).Iter()
+
+	// check payloads in batches
+	removed := 0
+	var batch []payloadFile
+	var file payloadFile
+	for {
+		more := iter.Next(&file)
+		if more {
+			batch = append(batch, file)
+		}
+
+		// check batch
+		if len(batch) == 100 || (!more && len(batch) > 0) {
+			n, err := c.cleanup(batch)
+			removed += n
+			if err != nil {
+				_ = iter.Close()
+				return removed, err
+			}
+			batch = batch[:0]
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	// close iterator
+	err = iter.Close()
+	if err != nil {
+		return removed, err
+	}
+
+	return removed, nil
+}
+
+func (c *Collection) removeAfter() (time.Duration, error) {
+	// get indexes
+	indexes, err := c.coll.Indexes()
+	if qerr, ok := err.(*mgo.QueryError); ok && qerr.Code == 26 {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	// find ended index
+	for _, index := range indexes {
+		if len(index.Key) == 1 && index.Key[0] == "ended" {
+			return index.ExpireAfter, nil
+		}
+	}
+
+	return 0, nil
+}
+
+func (c *Collection) endPayloads(ids []bson.ObjectId, ended time.Time) error {
+	// check threshold and ids
+	if c.threshold <= 0 || len(ids) == 0 {
+		return nil
+	}
+
+	// prepare update
+	update := bson.M{
+		"$set": bson.M{
+			"metadata.ended": ended,
+		},
+	}
+	if ended.IsZero() {
+		update = bson.M{
+			"$unset": bson.M{
+				"metadata.ended": "",
+			},
+		}
+	}
+
+	// update payloads
+	_, err := c.payloads().Files.UpdateAll(bson.M{
+		"metadata.job": bson.M{
+			"$in": ids,
+		},
+	}, update)
+
+	return err
+}
+
+func (c *Collection) removePayloads(ids []bson.ObjectId) error {
+	// check threshold
+	if c.threshold <= 0 {
+		return nil
+	}
+
+	// find payloads
+	var files []payloadFile
+	err := c.payloads().Find(bson.M{
+		"metadata.job": bson.M{
+			"$in": ids,
+		},
+	}).Select(bson.M{
+		"_id":          1,
+		"metadata.job": 1,
+	}).All(&files)
+	if err != nil || len(files) == 0 {
+		return err
+	}
+
+	// remove payloads of jobs that have been removed
+	_, err = c.cleanup(files)
+
+	return err
+}
+
+type payloadFile struct {
+	ID       bson.ObjectId `bson:"_id"`
+	Metadata struct {
+		Job bson.ObjectId
+	}
+}
+
+func (c *Collection) cleanup(files []payloadFile) (int, error) {
+	// collect job ids
+	ids := make([]bson.ObjectId, 0, len(files))
+	for _, file := range files {
+		ids = append(ids, file.Metadata.Job)
+	}
+
+	// load references
+	var jobs []Job
+	err := c.jobs.Find(bson.M{
+		"_id": bson.M{
+			"$in": ids,
+		},
+	}, FindOptions{
+		Select: bson.M{
+			"refs": 1,
+		},
+	}, &jobs)
+	if err != nil {
+		return 0, err
+	}
+
+	// collect referenced payloads
+	referenced := map[bson.ObjectId]bool{}
+	for _, job := range jobs {
+		if job.Refs != nil {
+			referenced[job.Refs.Params] = true
+			referenced[job.Refs.Result] = true
+		}
+	}
+
+	// remove unreferenced payloads
+	removed := 0
+	for _, file := range files {
+		if referenced[file.ID] {
+			continue
+		}
+
+		err = c.payloads().RemoveId(file.ID)
+		if err != nil && err != mgo.ErrNotFound {
+			return removed, err
+		}
+
+		removed++
+	}
+
+	return removed, nil
+}
+
+func (c *Collection) offload(id bson.ObjectId, field string, p payload) (bson.ObjectId, error) {
+	// encode payload
+	data, err := bson.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+
+	// check size
+	if len(data) <= c.threshold {
+		return "", nil
+	}
+
+	// create file
+	file, err := c.payloads().Create("")
+	if err != nil {
+		return "", err
+	}
+
+	// prepare file
+	ref := bson.NewObjectId()
+	file.SetId(ref)
+	file.SetMeta(bson.M{
+		"job":   id,
+		"field": field,
+	})
+
+	// write data
+	_, err = file.Write(data)
+	if err != nil {
+		file.Abort()
+		_ = file.Close()
+		return "", err
+	}
+
+	// close file
+	err = file.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return ref, nil
+}
+
+func (c *Collection) offloadParams(job *Job) error {
+	// check threshold
+	if c.threshold <= 0 {
+		return nil
+	}
+
+	// prepare payload
+	p := payload{Doc: job.Params}
+	if job.Encrypted != nil {
+		p.Encrypted = job.Encrypted.Params
+	}
+	if job.Compressed != nil {
+		p.Compressed = job.Compressed.Params
+	}
+	if p.Doc == nil && p.Encrypted == nil && p.Compressed == nil {
+		return nil
+	}
+
+	// offload payload
+	ref, err := c.offload(job.ID, "params", p)
+	if err != nil || ref == "" {
+		return err
+	}
+
+	// replace params
+	job.Params = nil
+	job.Encrypted = nil
+	job.Compressed = nil
+	job.Refs = &Refs{Params: ref}
+
+	return nil
+}
+
+func (c *Collection) offloadResult(id bson.ObjectId, update bson.M) error {
+	// check threshold
+	if c.threshold <= 0 {
+		return nil
+	}
+
+	// prepare payload
+	set := update["$set"].(bson.M)
+	var p payload
+	if ct, ok := set["encrypted.result"].(*Ciphertext); ok {
+		p.Encrypted = ct
+	} else if blob, ok := set["compressed.result"].(*Blob); ok {
+		p.Compressed = blob
+	} else if doc, ok := set["result"].(bson.M); ok && doc != nil {
+		p.Doc = doc
+	} else {
+		return nil
+	}
+
+	// offload payload
+	ref, err := c.offload(id, "result", p)
+	if err != nil || ref == "" {
+		return err
+	}
+
+	// replace result
+	delete(set, "result")
+	delete(set, "encrypted.result")
+	delete(set, "compressed.result")
+	set["refs.result"] = ref
+	update["$unset"].(bson.M)["result"] = ""
+
+	return nil
+}
+
+func (c *Collection) load(job *Job) error {
+	// check references
+	refs := job.Refs
+	if refs == nil {
+		return nil
+	}
+
+	// load params
+	if refs.Params != "" {
+		p, err := c.read(refs.Params)
+		if err != nil {
+			return err
+		}
+		job.Params = p.Doc
+		if p.Encrypted != nil {
+			if job.Encrypted == nil {
+				job.Encrypted = &Envelope{}
+			}
+			job.Encrypted.Params = p.Encrypted
+		}
+		if p.Compressed != nil {
+			if job.Compressed == nil {
+				job.Compressed = &Compressed{}
+			}
+			job.Compressed.Params = p.Compressed
+		}
+	}
+
+	// load result
+	if refs.Result != "" {
+		p, err := c.read(refs.Result)
+		if err != nil {
+			return err
+		}
+		job.Result = p.Doc
+		if p.Encrypted != nil {
+			if job.Encrypted == nil {
+				job.Encrypted = &Envelope{}
+			}
+			job.Encrypted.Result = p.Encrypted
+		}
+		if p.Compressed != nil {
+			if job.Compressed == nil {
+				job.Compressed = &Compressed{}
+			}
+			job.Compressed.Result = p.Compressed
+		}
+	}
+
+	// clear references
+	job.Refs = nil
+
+	return nil
+}
+
+func (c *Collection) read(ref bson.ObjectId) (*payload, error) {
+	// open file
+	file, err := c.payloads().OpenId(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// read data
+	data, err := io.ReadAll(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	// close file
+	err = file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// decode payload
+	var p payload
+	err = bson.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (c *Collection) payloads() *mgo.GridFS {
+	return c.coll.Database.GridFS(c.coll.Name + ".payloads")
+}
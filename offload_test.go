@@ -0,0 +1,238 @@
+package mgojq
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionSetOffload(t *testing.T) {
+	requireDB(t)
+
+	jqc := newCollection("test-collection-set-offload")
+	dbc := jqc.jobs
+
+	jqc.SetOffload(100)
+
+	large := strings.Repeat("x", 200)
+
+	id1, err := jqc.Enqueue("foo", bson.M{"data": large}, 0)
+	assert.NoError(t, err)
+
+	id2, err := jqc.Enqueue("foo", bson.M{"data": "small"}, 0)
+	assert.NoError(t, err)
+
+	var doc bson.M
+	err = dbc.FindOne(bson.M{"_id": id1}, FindOptions{}, &doc)
+	assert.NoError(t, err)
+	assert.Empty(t, doc["params"])
+	assert.NotEmpty(t, doc["refs"].(bson.M)["params"])
+
+	err = dbc.FindOne(bson.M{"_id": id2}, FindOptions{}, &doc)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"data": "small"}, doc["params"])
+	assert.Nil(t, doc["refs"])
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id1, job.ID)
+	assert.Equal(t, bson.M{"data": large}, job.Params)
+	assert.Nil(t, job.Refs)
+
+	err = jqc.Complete(id1, bson.M{"data": large})
+	assert.NoError(t, err)
+
+	err = dbc.FindOne(bson.M{"_id": id1}, FindOptions{}, &doc)
+	assert.NoError(t, err)
+	assert.Nil(t, doc["result"])
+	assert.NotEmpty(t, doc["refs"].(bson.M)["result"])
+
+	job, err = jqc.Fetch(id1)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"data": large}, job.Params)
+	assert.Equal(t, bson.M{"data": large}, job.Result)
+
+	jobs, _, err := jqc.List(Query{
+		Exclude: []string{"params", "result"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 2)
+	assert.Nil(t, jobs[0].Params)
+	assert.Nil(t, jobs[0].Result)
+}
+
+func TestOffloadEncryption(t *testing.T) {
+	requireDB(t)
+
+	jqc := newCollection("test-offload-encryption")
+	dbc := jqc.jobs
+
+	jqc.SetOffload(100)
+	jqc.SetEncryption(&Keyring{
+		Current: "k1",
+		Keys: map[string][]byte{
+			"k1": bytes.Repeat([]byte{1}, 32),
+		},
+	})
+
+	large := strings.Repeat("x", 200)
+
+	id, err := jqc.Enqueue("foo", bson.M{"data": large}, 0)
+	assert.NoError(t, err)
+
+	var doc bson.M
+	err = dbc.FindOne(bson.M{"_id": id}, FindOptions{}, &doc)
+	assert.NoError(t, err)
+	assert.Empty(t, doc["params"])
+	assert.Nil(t, doc["encrypted"])
+	assert.NotEmpty(t, doc["refs"].(bson.M)["params"])
+
+	job, err := jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"data": large}, job.Params)
+}
+
+func TestBulkOffload(t *testing.T) {
+	requireDB(t)
+
+	jqc := newCollection("test-bulk-offload")
+	files := db.C("test-bulk-offload.payloads.files")
+
+	jqc.SetOffload(100)
+
+	large := strings.Repeat("x", 200)
+
+	bulk := jqc.Bulk()
+	id := bulk.Enqueue("foo", bson.M{"data": large}, 0)
+
+	count, err := files.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	err = bulk.Run()
+	assert.NoError(t, err)
+
+	count, err = files.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	job, err := jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"data": large}, job.Params)
+}
+
+func TestCollectionCleanup(t *testing.T) {
+	requireDB(t)
+
+	jqc := newCollection("test-collection-cleanup")
+	dbc := jqc.jobs
+	files := db.C("test-collection-cleanup.payloads.files")
+
+	jqc.SetOffload(100)
+
+	err := jqc.EnsureIndexes(time.Hour)
+	assert.NoError(t, err)
+
+	large := strings.Repeat("x", 200)
+
+	id1, err := jqc.Enqueue("foo", bson.M{"data": large}, 0)
+	assert.NoError(t, err)
+
+	id2, err := jqc.Enqueue("foo", bson.M{"data": large}, 0)
+	assert.NoError(t, err)
+
+	_, err = jqc.Enqueue("foo", bson.M{"data": large}, 0)
+	assert.NoError(t, err)
+
+	err = jqc.Cancel(id1, "some reason")
+	assert.NoError(t, err)
+
+	err = jqc.Cancel(id2, "some reason")
+	assert.NoError(t, err)
+
+	count, err := files.Find(bson.M{"metadata.ended": bson.M{"$exists": true}}).Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	n, err := jqc.Cleanup()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	_, err = files.UpdateAll(bson.M{"metadata.ended": bson.M{"$exists": true}}, bson.M{
+		"$set": bson.M{
+			"metadata.ended": time.Now().Add(-2 * time.Hour),
+		},
+	})
+	assert.NoError(t, err)
+
+	n, err = jqc.Cleanup()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	_, err = dbc.RemoveAll(bson.M{"_id": id1})
+	assert.NoError(t, err)
+
+	n, err = jqc.Cleanup()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	count, err = files.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestCollectionCleanupDeleteWhere(t *testing.T) {
+	requireDB(t)
+
+	jqc := newCollection("test-collection-cleanup-delete-where")
+	files := db.C("test-collection-cleanup-delete-where.payloads.files")
+
+	jqc.SetOffload(100)
+
+	large := strings.Repeat("x", 200)
+
+	id, err := jqc.Enqueue("foo", bson.M{"data": large}, 0)
+	assert.NoError(t, err)
+
+	_, err = jqc.Enqueue("bar", bson.M{"data": large}, 0)
+	assert.NoError(t, err)
+
+	err = jqc.Cancel(id, "some reason")
+	assert.NoError(t, err)
+
+	n, err := jqc.DeleteWhere(Filter{
+		Names: []string{"foo"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	count, err := files.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestEnsureIndexesOffload(t *testing.T) {
+	requireDB(t)
+
+	jqc := newCollection("test-ensure-indexes-offload")
+
+	err := jqc.EnsureIndexes(time.Hour)
+	assert.NoError(t, err)
+
+	names, err := db.CollectionNames()
+	assert.NoError(t, err)
+	assert.NotContains(t, names, "test-ensure-indexes-offload.payloads.files")
+
+	jqc.SetOffload(100)
+
+	err = jqc.EnsureIndexes(time.Hour)
+	assert.NoError(t, err)
+
+	names, err = db.CollectionNames()
+	assert.NoError(t, err)
+	assert.Contains(t, names, "test-ensure-indexes-offload.payloads.files")
+}
@@ -1,51 +1,135 @@
 package mgojq
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
 	"time"
 
+	"github.com/globalsign/mgo/bson"
+	"go.opentelemetry.io/otel/trace"
+
 	"gopkg.in/tomb.v2"
 )
 
+// The interval in which a pool refreshes the list of paused names.
+const pauseRefresh = time.Second
+
+// The interval in which a pool sweeps expired jobs.
+const sweepInterval = 10 * time.Second
+
+// The interval in which a pool sends heartbeats to the worker registry.
+const heartbeatInterval = time.Second
+
+// The duration after which a pool that has not sent a heartbeat is considered
+// stale and its jobs are reclaimed.
+const staleTimeout = 10 * heartbeatInterval
+
 // Worker is a function that processes a job. The function must complete, fail
-// or cancel the job on its own. If the provided channel is closes the worker
-// should immediately finish the job and cancel long running jobs.
+// or cancel the job on its own. If the provided channel is closed the worker
+// should immediately finish the job and cancel long running jobs. The channel
+// is closed when the pool is closing or the timeout of the job has passed.
+// Reporting progress restarts the timeout.
 type Worker func(c *Collection, j *Job, quit <-chan struct{}) error
 
+// Middleware wraps a worker to add functionality like logging or recovery.
+type Middleware func(Worker) Worker
+
 // Pool manages multiple goroutines that dequeue jobs.
 type Pool struct {
 	size     int
 	interval time.Duration
 	timeout  time.Duration
 	workers  map[string]Worker
+	chains   map[string][]Middleware
+	global   []Middleware
 	names    []string
+	queues   []string
+	timeouts map[string]time.Duration
 	jobs     chan *Job
+	identity string
+
+	observers []Observer
+	logger    *slog.Logger
 
 	started bool
 	coll    *Collection
 
+	paused    []string
+	refreshed time.Time
+
 	tomb tomb.Tomb
 }
 
-// NewPool will create a new pool.
+// NewPool will create a new pool. The pool uses an identity made from the
+// hostname, process id and a unique pool id to claim jobs.
 func NewPool(size int, interval, timeout time.Duration) *Pool {
+	// get hostname
+	hostname, _ := os.Hostname()
+
 	return &Pool{
 		interval: interval,
 		timeout:  timeout,
 		size:     size,
 		workers:  make(map[string]Worker),
+		chains:   make(map[string][]Middleware),
+		timeouts: make(map[string]time.Duration),
 		jobs:     make(chan *Job),
+		identity: fmt.Sprintf("%s:%d:%s", hostname, os.Getpid(), bson.NewObjectId().Hex()),
 	}
 }
 
-// Register will register the specified worker for the specified job name.
-func (p *Pool) Register(name string, worker Worker) {
+// Identity will return the identity of the pool.
+func (p *Pool) Identity() string {
+	return p.identity
+}
+
+// SetIdentity will set the identity of the pool that is stored on dequeued
+// jobs. It must be called before the pool is started.
+func (p *Pool) SetIdentity(identity string) {
+	p.identity = identity
+}
+
+// Size will return the number of workers of the pool.
+func (p *Pool) Size() int {
+	return p.size
+}
+
+// Observe will add the specified observer that receives processing and
+// processed events for every job that is handed to a worker. Observers must
+// be added before the pool is started.
+func (p *Pool) Observe(observer Observer) {
+	p.observers = append(p.observers, observer)
+}
+
+// Use will add the specified middleware to all workers. Pool middleware wraps
+// the middleware supplied to Register. It must be called before the pool is
+// started.
+func (p *Pool) Use(middleware ...Middleware) {
+	p.global = append(p.global, middleware...)
+}
+
+// SetQueues will restrict the pool to jobs that belong to one of the specified
+// queues. It must be called before the pool is started.
+func (p *Pool) SetQueues(queues ...string) {
+	p.queues = queues
+}
+
+// Register will register the specified worker for the specified job name. The
+// optional middleware is only applied to this worker. The first middleware is
+// the outermost one. The timeout of the job definition with the same name is
+// used as the deadline for jobs that have no timeout of their own once the
+// pool is started.
+func (p *Pool) Register(name string, worker Worker, middleware ...Middleware) {
 	// add name if missing
 	if _, ok := p.workers[name]; !ok {
 		p.names = append(p.names, name)
 	}
 
-	// set or update worker
+	// set or update worker and middleware
 	p.workers[name] = worker
+	p.chains[name] = middleware
 }
 
 // Start will start the worker pool. The worker pool will dequeue and process
@@ -63,8 +147,32 @@ func (p *Pool) Start(coll *Collection) <-chan struct{} {
 	// set collection
 	p.coll = coll
 
+	// inherit logger
+	if p.logger == nil {
+		p.logger = coll.logger
+	}
+
+	// get definition timeouts
+	for _, name := range p.names {
+		if def, ok := coll.Definition(name); ok && def.Timeout > 0 {
+			p.timeouts[name] = def.Timeout
+		}
+	}
+
+	// wrap workers
+	for name, worker := range p.workers {
+		p.workers[name] = chain(chain(worker, p.chains[name]), p.global)
+	}
+
+	// log start
+	p.logger.Info("pool started",
+		slog.String("identity", p.identity),
+		slog.Int("size", p.size),
+		slog.Any("names", p.names),
+	)
+
 	// run dequeuer
-	p.tomb.Go(p.dequeuer)
+	p.tomb.Go(p.routine("dequeuer", p.dequeuer))
 
 	// return channel
 	return p.tomb.Dying()
@@ -87,13 +195,31 @@ func (p *Pool) Wait() error {
 func (p *Pool) dequeuer() error {
 	// run workers
 	for i := 0; i < p.size; i++ {
-		p.tomb.Go(p.worker)
+		p.tomb.Go(p.routine("worker", p.worker))
 	}
 
+	// run sweeper
+	p.tomb.Go(p.routine("sweeper", p.sweeper))
+
+	// run heartbeater
+	p.tomb.Go(p.routine("heartbeater", p.heartbeater))
+
 	for {
+		var names []string
+		var job *Job
+		var err error
+
 	dequeue:
+		// get active names
+		names, err = p.active()
+		if err != nil {
+			return err
+		} else if len(names) == 0 {
+			goto wait
+		}
+
 		// dequeue next job
-		job, err := p.coll.Dequeue(p.names, p.timeout)
+		job, err = p.coll.dequeue(p.identity, names, p.queues, p.timeout)
 		if err != nil {
 			return err
 		} else if job == nil {
@@ -101,7 +227,11 @@ func (p *Pool) dequeuer() error {
 		}
 
 		// queue job for processing
-		p.jobs <- job
+		select {
+		case p.jobs <- job:
+		case <-p.tomb.Dying():
+			return tomb.ErrDying
+		}
 
 		// get next job
 		goto dequeue
@@ -117,6 +247,76 @@ func (p *Pool) dequeuer() error {
 	}
 }
 
+func (p *Pool) active() ([]string, error) {
+	// refresh paused names if outdated
+	if time.Since(p.refreshed) >= pauseRefresh {
+		paused, err := p.coll.Paused()
+		if err != nil {
+			return nil, err
+		}
+
+		p.paused = paused
+		p.refreshed = time.Now()
+	}
+
+	return without(p.names, p.paused), nil
+}
+
+func (p *Pool) sweeper() error {
+	for {
+		// wait
+		select {
+		case <-p.tomb.Dying():
+			return tomb.ErrDying
+		case <-time.After(sweepInterval):
+		}
+
+		// sweep expired jobs
+		_, err := p.coll.Sweep(p.timeout)
+		if err != nil {
+			return err
+		}
+
+		// remove payloads of removed jobs
+		if p.coll.threshold > 0 {
+			_, err = p.coll.Cleanup()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *Pool) heartbeater() error {
+	// prepare registration
+	reg := Registration{
+		ID:       p.identity,
+		Names:    p.names,
+		Capacity: p.size,
+	}
+
+	for {
+		// send heartbeat
+		err := p.coll.Heartbeat(reg)
+		if err != nil {
+			return err
+		}
+
+		// reclaim jobs from stale workers
+		_, err = p.coll.Reclaim(staleTimeout)
+		if err != nil {
+			return err
+		}
+
+		// wait
+		select {
+		case <-p.tomb.Dying():
+			return p.coll.Unregister(p.identity)
+		case <-time.After(heartbeatInterval):
+		}
+	}
+}
+
 func (p *Pool) worker() error {
 	for {
 		// wait
@@ -124,14 +324,112 @@ func (p *Pool) worker() error {
 		case <-p.tomb.Dying():
 			return tomb.ErrDying
 		case job := <-p.jobs:
-			// get function
-			fn := p.workers[job.Name]
-
-			// call function
-			err := fn(p.coll, job, p.tomb.Dying())
+			// execute job
+			err := p.execute(job)
 			if err != nil {
 				return err
 			}
 		}
 	}
 }
+
+func (p *Pool) execute(job *Job) error {
+	// get function
+	fn := p.workers[job.Name]
+
+	// get timeout
+	timeout := job.Timeout
+	if timeout <= 0 {
+		timeout = p.timeouts[job.Name]
+	}
+	if timeout <= 0 {
+		timeout = p.timeout
+	}
+
+	// prepare deadline
+	deadline := time.NewTimer(time.Until(job.Started.Add(timeout)))
+	defer deadline.Stop()
+
+	// receive heartbeats
+	beats := make(chan struct{}, 1)
+	p.coll.beats.Store(job.ID, beats)
+	defer p.coll.beats.Delete(job.ID)
+
+	// close quit channel on shutdown or deadline and extend the deadline
+	// when progress is reported
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-p.tomb.Dying():
+			case <-deadline.C:
+				p.logger.Warn("job timed out",
+					slog.String("id", job.ID.Hex()),
+					slog.String("name", job.Name),
+					slog.Int("attempt", job.Attempts),
+					slog.Duration("timeout", timeout),
+				)
+			case <-beats:
+				deadline.Reset(timeout)
+				continue
+			case <-done:
+			}
+			close(quit)
+			return
+		}
+	}()
+	defer close(done)
+
+	// notify observers
+	p.notify(Event{
+		Type:    EventProcessing,
+		Job:     job.ID,
+		Name:    job.Name,
+		Attempt: job.Attempts,
+	})
+
+	// start span
+	ctx, span := tracer().Start(context.Background(), "mgojq.process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(links(job.Trace)...),
+		trace.WithAttributes(jobAttributes(job)...),
+	)
+	job.ctx = ctx
+
+	// track context
+	untrack := p.coll.track(job)
+
+	// call function
+	start := time.Now()
+	err := fn(p.coll, job, quit)
+
+	// untrack context and end span
+	untrack()
+	endSpan(span, err)
+
+	// notify observers
+	p.notify(Event{
+		Type:     EventProcessed,
+		Job:      job.ID,
+		Name:     job.Name,
+		Attempt:  job.Attempts,
+		Duration: time.Since(start),
+	})
+
+	return err
+}
+
+func (p *Pool) notify(event Event) {
+	logEvent(p.logger, event)
+	notify(p.observers, event)
+}
+
+func chain(worker Worker, middleware []Middleware) Worker {
+	// wrap worker starting with the innermost middleware
+	for i := len(middleware) - 1; i >= 0; i-- {
+		worker = middleware[i](worker)
+	}
+
+	return worker
+}
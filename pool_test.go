@@ -5,12 +5,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/globalsign/mgo/bson"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestPool(t *testing.T) {
-	dbc := db.C("test-pool")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-pool")
 
 	counter := 0
 
@@ -34,9 +34,41 @@ func TestPool(t *testing.T) {
 	assert.Equal(t, 3, counter)
 }
 
+func TestPoolQueues(t *testing.T) {
+	jqc := newCollection("test-pool-queues")
+
+	id1, err := jqc.EnqueueWith("foo", nil, Options{Queue: "q1"})
+	assert.NoError(t, err)
+
+	id2, err := jqc.EnqueueWith("foo", nil, Options{Queue: "q2"})
+	assert.NoError(t, err)
+
+	done := make(chan bson.ObjectId, 2)
+
+	pool := NewPool(1, 0, time.Hour)
+	pool.SetQueues("q2")
+	pool.Register("foo", func(c *Collection, j *Job, quit <-chan struct{}) error {
+		done <- j.ID
+		return c.Complete(j.ID, nil)
+	})
+
+	pool.Start(jqc)
+
+	assert.Equal(t, id2, <-done)
+
+	time.Sleep(10 * time.Millisecond)
+	pool.Close()
+	assert.NoError(t, pool.Wait())
+
+	assert.Empty(t, done)
+
+	job, err := jqc.Fetch(id1)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusEnqueued, job.Status)
+}
+
 func TestPoolParallel(t *testing.T) {
-	dbc := db.C("test-pool-parallel")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-pool-parallel")
 
 	counter := 0
 
@@ -62,8 +94,7 @@ func TestPoolParallel(t *testing.T) {
 }
 
 func TestPoolWait(t *testing.T) {
-	dbc := db.C("test-pool-wait")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-pool-wait")
 
 	counter := 0
 
@@ -89,8 +120,7 @@ func TestPoolWait(t *testing.T) {
 }
 
 func TestPoolError(t *testing.T) {
-	dbc := db.C("test-pool-error")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-pool-error")
 
 	pool := NewPool(1, 0, time.Hour)
 	pool.Register("foo", func(c *Collection, j *Job, quit <-chan struct{}) error {
@@ -105,8 +135,7 @@ func TestPoolError(t *testing.T) {
 }
 
 func TestPoolStartError(t *testing.T) {
-	dbc := db.C("test-pool-start-error")
-	jqc := Wrap(dbc)
+	jqc := newCollection("test-pool-start-error")
 
 	pool := NewPool(1, 0, time.Hour)
 	pool.Register("foo", func(c *Collection, j *Job, quit <-chan struct{}) error {
@@ -120,3 +149,229 @@ func TestPoolStartError(t *testing.T) {
 		pool.Start(nil)
 	})
 }
+
+func TestPoolPause(t *testing.T) {
+	jqc := newCollection("test-pool-pause")
+
+	counter := 0
+
+	err := jqc.Pause("foo")
+	assert.NoError(t, err)
+
+	pool := NewPool(1, 10*time.Millisecond, time.Hour)
+	pool.Register("foo", func(c *Collection, j *Job, quit <-chan struct{}) error {
+		counter++
+		c.Complete(j.ID, nil)
+		return nil
+	})
+
+	pool.Start(jqc)
+
+	jqc.Enqueue("foo", nil, 0)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, counter)
+
+	err = jqc.Resume("foo")
+	assert.NoError(t, err)
+
+	time.Sleep(pauseRefresh + 50*time.Millisecond)
+
+	pool.Close()
+	assert.NoError(t, pool.Wait())
+
+	assert.Equal(t, 1, counter)
+}
+
+func TestPoolJobTimeout(t *testing.T) {
+	jqc := newCollection("test-pool-job-timeout")
+
+	done := make(chan time.Duration, 1)
+
+	pool := NewPool(1, 0, time.Hour)
+	pool.Register("foo", func(c *Collection, j *Job, quit <-chan struct{}) error {
+		start := time.Now()
+		<-quit
+		done <- time.Since(start)
+		return c.Cancel(j.ID, "timeout")
+	})
+
+	pool.Start(jqc)
+
+	jqc.EnqueueWith("foo", nil, Options{
+		Timeout: 50 * time.Millisecond,
+	})
+
+	select {
+	case d := <-done:
+		assert.True(t, d < time.Second)
+	case <-time.After(time.Second):
+		t.Error("worker has not been stopped")
+	}
+
+	pool.Close()
+	assert.NoError(t, pool.Wait())
+}
+
+func TestPoolDefinitionTimeout(t *testing.T) {
+	jqc := newCollection("test-pool-definition-timeout")
+
+	_, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	jqc.Define("foo", JobDefinition{
+		Timeout: 50 * time.Millisecond,
+	})
+
+	done := make(chan time.Duration, 1)
+
+	pool := NewPool(1, 0, time.Hour)
+	pool.Register("foo", func(c *Collection, j *Job, quit <-chan struct{}) error {
+		start := time.Now()
+		<-quit
+		done <- time.Since(start)
+		return c.Cancel(j.ID, "timeout")
+	})
+
+	pool.Start(jqc)
+
+	select {
+	case d := <-done:
+		assert.True(t, d < time.Second)
+	case <-time.After(time.Second):
+		t.Error("worker has not been stopped")
+	}
+
+	pool.Close()
+	assert.NoError(t, pool.Wait())
+}
+
+func TestPoolJobProgress(t *testing.T) {
+	jqc := newCollection("test-pool-job-progress")
+
+	done := make(chan time.Duration, 1)
+
+	pool := NewPool(1, 0, time.Hour)
+	pool.Register("foo", func(c *Collection, j *Job, quit <-chan struct{}) error {
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			select {
+			case <-quit:
+				done <- time.Since(start)
+				return nil
+			case <-time.After(20 * time.Millisecond):
+			}
+			err := c.Progress(j.ID, float64(i)*20, "")
+			if err != nil {
+				return err
+			}
+		}
+		<-quit
+		done <- time.Since(start)
+		return c.Cancel(j.ID, "timeout")
+	})
+
+	pool.Start(jqc)
+
+	jqc.EnqueueWith("foo", nil, Options{
+		Timeout: 100 * time.Millisecond,
+	})
+
+	select {
+	case d := <-done:
+		assert.True(t, d > 150*time.Millisecond)
+		assert.True(t, d < time.Second)
+	case <-time.After(time.Second):
+		t.Error("worker has not been stopped")
+	}
+
+	pool.Close()
+	assert.NoError(t, pool.Wait())
+}
+
+func TestPoolIdentity(t *testing.T) {
+	jqc := newCollection("test-pool-identity")
+
+	var worker string
+
+	pool := NewPool(1, 0, time.Hour)
+	assert.NotEmpty(t, pool.Identity())
+
+	pool.SetIdentity("foo")
+	assert.Equal(t, "foo", pool.Identity())
+
+	pool.Register("foo", func(c *Collection, j *Job, quit <-chan struct{}) error {
+		worker = j.Worker
+		c.Complete(j.ID, nil)
+		return nil
+	})
+
+	pool.Start(jqc)
+
+	jqc.Enqueue("foo", nil, 0)
+
+	time.Sleep(10 * time.Millisecond)
+	pool.Close()
+	assert.NoError(t, pool.Wait())
+
+	assert.Equal(t, "foo", worker)
+}
+
+func TestPoolRegistry(t *testing.T) {
+	jqc := newCollection("test-pool-registry")
+
+	pool := NewPool(2, 0, time.Hour)
+	pool.Register("foo", func(c *Collection, j *Job, quit <-chan struct{}) error {
+		return nil
+	})
+
+	pool.Start(jqc)
+
+	time.Sleep(100 * time.Millisecond)
+
+	list, err := jqc.Workers()
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, pool.Identity(), list[0].ID)
+	assert.Equal(t, []string{"foo"}, list[0].Names)
+	assert.Equal(t, 2, list[0].Capacity)
+
+	pool.Close()
+	assert.NoError(t, pool.Wait())
+
+	list, err = jqc.Workers()
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestPoolMiddleware(t *testing.T) {
+	jqc := newCollection("test-pool-middleware")
+
+	var calls []string
+
+	trace := func(name string) Middleware {
+		return func(next Worker) Worker {
+			return func(c *Collection, j *Job, quit <-chan struct{}) error {
+				calls = append(calls, name)
+				return next(c, j, quit)
+			}
+		}
+	}
+
+	pool := NewPool(1, 0, time.Hour)
+	pool.Use(trace("global1"), trace("global2"))
+	pool.Register("foo", func(c *Collection, j *Job, quit <-chan struct{}) error {
+		calls = append(calls, "worker")
+		return c.Complete(j.ID, nil)
+	}, trace("local"))
+
+	pool.Start(jqc)
+
+	jqc.Enqueue("foo", nil, 0)
+
+	time.Sleep(10 * time.Millisecond)
+	pool.Close()
+	assert.NoError(t, pool.Wait())
+
+	assert.Equal(t, []string{"global1", "global2", "local", "worker"}, calls)
+}
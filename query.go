@@ -0,0 +1,259 @@
+package mgojq
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// ErrInvalidCursor is returned by List if the cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrInvalidSort is returned by List if the sort field is not supported.
+var ErrInvalidSort = errors.New("invalid sort")
+
+// A Filter describes a set of jobs. All specified conditions must match.
+type Filter struct {
+	// The job names to match.
+	Names []string
+
+	// The job statuses to match.
+	Statuses []string
+
+	// The job queues to match.
+	Queues []string
+
+	// Match jobs created at or after the specified time.
+	CreatedAfter time.Time
+
+	// Match jobs created before the specified time.
+	CreatedBefore time.Time
+
+	// Conditions on params fields. The values may also contain query
+	// operators e.g. {"count": {"$gt": 5}}.
+	Params bson.M
+}
+
+func (f Filter) query() bson.M {
+	// prepare query
+	query := bson.M{}
+
+	// add names
+	if len(f.Names) > 0 {
+		query["name"] = bson.M{
+			"$in": f.Names,
+		}
+	}
+
+	// add statuses
+	if len(f.Statuses) > 0 {
+		query["status"] = bson.M{
+			"$in": f.Statuses,
+		}
+	}
+
+	// add queues
+	if len(f.Queues) > 0 {
+		query["queue"] = bson.M{
+			"$in": f.Queues,
+		}
+	}
+
+	// add created range
+	if !f.CreatedAfter.IsZero() || !f.CreatedBefore.IsZero() {
+		created := bson.M{}
+		if !f.CreatedAfter.IsZero() {
+			created["$gte"] = f.CreatedAfter
+		}
+		if !f.CreatedBefore.IsZero() {
+			created["$lt"] = f.CreatedBefore
+		}
+		query["created"] = created
+	}
+
+	// add params
+	for key, value := range f.Params {
+		query["params."+key] = value
+	}
+
+	return query
+}
+
+// A Query describes a page of jobs returned by List.
+type Query struct {
+	// The filter to apply.
+	Filter Filter
+
+	// The field to sort by. Supported fields are "_id", "created" and
+	// "delayed". Prefix the field with "-" to sort descending. Defaults to
+	// "_id".
+	Sort string
+
+	// The maximum number of jobs to return. Zero returns all jobs.
+	Limit int
+
+	// The cursor returned by a previous call to List to get the next page.
+	Cursor string
+
+	// Fields that should not be loaded e.g. "params" or "result".
+	Exclude []string
+}
+
+type cursor struct {
+	Value interface{}   `bson:"v"`
+	ID    bson.ObjectId `bson:"i"`
+}
+
+// List will return the jobs matching the specified query. If more jobs are
+// available, a cursor is returned that can be used to get the next page.
+func (c *Collection) List(q Query) ([]Job, string, error) {
+	// get sort field and direction
+	field := strings.TrimPrefix(q.Sort, "-")
+	desc := strings.HasPrefix(q.Sort, "-")
+	if field == "" {
+		field = "_id"
+	}
+
+	// check field
+	if field != "_id" && field != "created" && field != "delayed" {
+		return nil, "", ErrInvalidSort
+	}
+
+	// get query
+	query := q.Filter.query()
+
+	// add cursor
+	if q.Cursor != "" {
+		// decode cursor
+		cur, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+
+		// get operator
+		op := "$gt"
+		if desc {
+			op = "$lt"
+		}
+
+		// add condition
+		if field == "_id" {
+			query["_id"] = bson.M{op: cur.ID}
+		} else {
+			query["$or"] = []bson.M{
+				{field: bson.M{op: cur.Value}},
+				{field: cur.Value, "_id": bson.M{op: cur.ID}},
+			}
+		}
+	}
+
+	// prepare sort
+	sort := []string{field, "_id"}
+	if desc {
+		sort = []string{"-" + field, "-_id"}
+	}
+	if field == "_id" {
+		sort = sort[:1]
+	}
+
+	// prepare projection
+	var selector bson.M
+	for _, f := range q.Exclude {
+		if f != field && f != "_id" {
+			if selector == nil {
+				selector = bson.M{}
+			}
+			selector[f] = 0
+
+			// exclude encrypted, compressed and offloaded values
+			switch f {
+			case "params", "result":
+				selector["encrypted."+f] = 0
+				selector["compressed."+f] = 0
+				selector["refs."+f] = 0
+			case "error":
+				selector["encrypted."+f] = 0
+			}
+		}
+	}
+
+	// prepare options
+	opts := FindOptions{
+		Sort:   sort,
+		Select: selector,
+	}
+	if q.Limit > 0 {
+		opts.Limit = q.Limit + 1
+	}
+
+	// find jobs
+	var jobs []Job
+	err := c.jobs.Find(query, opts, &jobs)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// decrypt jobs
+	err = c.openAll(jobs)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// check for more jobs
+	if q.Limit <= 0 || len(jobs) <= q.Limit {
+		return jobs, "", nil
+	}
+
+	// truncate list
+	jobs = jobs[:q.Limit]
+
+	// get last job
+	last := jobs[len(jobs)-1]
+
+	// prepare cursor
+	cur := cursor{ID: last.ID}
+	switch field {
+	case "created":
+		cur.Value = last.Created
+	case "delayed":
+		cur.Value = last.Delayed
+	}
+
+	// encode cursor
+	next, err := encodeCursor(cur)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return jobs, next, nil
+}
+
+func encodeCursor(cur cursor) (string, error) {
+	// marshal cursor
+	data, err := bson.Marshal(cur)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(str string) (cursor, error) {
+	// decode string
+	data, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	// unmarshal cursor
+	var cur cursor
+	err = bson.Unmarshal(data, &cur)
+	if err != nil || !cur.ID.Valid() {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	return cur, nil
+}
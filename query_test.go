@@ -0,0 +1,109 @@
+package mgojq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionList(t *testing.T) {
+	jqc := newCollection("test-coll-list")
+
+	var ids []bson.ObjectId
+	for i := 0; i < 5; i++ {
+		id, err := jqc.Enqueue("foo", bson.M{"i": i, "tenant": "a"}, 0)
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	_, err := jqc.Enqueue("bar", bson.M{"tenant": "a"}, 0)
+	assert.NoError(t, err)
+
+	_, err = jqc.Enqueue("foo", bson.M{"tenant": "b"}, 0)
+	assert.NoError(t, err)
+
+	query := Query{
+		Filter: Filter{
+			Names:    []string{"foo"},
+			Statuses: []string{StatusEnqueued},
+			Params:   bson.M{"tenant": "a"},
+		},
+		Limit:   2,
+		Exclude: []string{"params"},
+	}
+
+	var list []bson.ObjectId
+	for {
+		jobs, cursor, err := jqc.List(query)
+		assert.NoError(t, err)
+
+		for _, job := range jobs {
+			assert.Equal(t, "foo", job.Name)
+			assert.Nil(t, job.Params)
+			list = append(list, job.ID)
+		}
+
+		if cursor == "" {
+			break
+		}
+
+		query.Cursor = cursor
+	}
+
+	assert.Equal(t, ids, list)
+}
+
+func TestCollectionListSort(t *testing.T) {
+	jqc := newCollection("test-coll-list-sort")
+
+	var ids []bson.ObjectId
+	for i := 0; i < 3; i++ {
+		id, err := jqc.Enqueue("foo", nil, time.Duration(3-i)*time.Minute)
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	jobs, cursor, err := jqc.List(Query{
+		Sort:  "delayed",
+		Limit: 2,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 2)
+	assert.Equal(t, ids[2], jobs[0].ID)
+	assert.Equal(t, ids[1], jobs[1].ID)
+	assert.NotEmpty(t, cursor)
+
+	jobs, cursor, err = jqc.List(Query{
+		Sort:   "delayed",
+		Limit:  2,
+		Cursor: cursor,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, ids[0], jobs[0].ID)
+	assert.Empty(t, cursor)
+
+	jobs, _, err = jqc.List(Query{
+		Sort: "-_id",
+		Filter: Filter{
+			CreatedAfter:  time.Now().Add(-time.Minute),
+			CreatedBefore: time.Now().Add(time.Minute),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 3)
+	assert.Equal(t, ids[2], jobs[0].ID)
+	assert.Equal(t, ids[0], jobs[2].ID)
+
+	_, _, err = jqc.List(Query{
+		Cursor: "foo",
+	})
+	assert.Equal(t, ErrInvalidCursor, err)
+
+	_, _, err = jqc.List(Query{
+		Sort: "name",
+	})
+	assert.Equal(t, ErrInvalidSort, err)
+}
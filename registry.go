@@ -0,0 +1,165 @@
+package mgojq
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// The error that is set on jobs that have been reclaimed from stale workers.
+const orphanedError = "orphaned"
+
+// A Registration describes a worker that has registered itself in the worker
+// registry of a collection.
+type Registration struct {
+	// The identity of the worker.
+	ID string `bson:"_id"`
+
+	// The job names the worker processes.
+	Names []string
+
+	// The number of jobs the worker can process concurrently.
+	Capacity int
+
+	// The time when the worker has been registered.
+	Registered time.Time
+
+	// The time of the last heartbeat.
+	Heartbeat time.Time
+}
+
+// Heartbeat will register the specified worker in the worker registry or
+// refresh its heartbeat if it is already registered.
+func (c *Collection) Heartbeat(reg Registration) error {
+	return c.registry().Upsert(bson.M{"_id": reg.ID}, bson.M{
+		"$set": bson.M{
+			"names":     reg.Names,
+			"capacity":  reg.Capacity,
+			"heartbeat": time.Now(),
+		},
+		"$setOnInsert": bson.M{
+			"registered": time.Now(),
+		},
+	})
+}
+
+// Unregister will remove the specified worker from the worker registry.
+func (c *Collection) Unregister(worker string) error {
+	_, err := c.registry().RemoveAll(bson.M{"_id": worker})
+	return err
+}
+
+// Workers will return all workers in the worker registry.
+func (c *Collection) Workers() ([]Registration, error) {
+	var list []Registration
+	err := c.registry().Find(nil, FindOptions{
+		Sort: []string{"_id"},
+	}, &list)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Reclaim will fail all dequeued jobs that are owned by workers whose last
+// heartbeat is older than the specified duration and remove those workers from
+// the registry. Reclaimed jobs can be dequeued again immediately. It returns
+// the number of reclaimed jobs. Observers are notified about every reclaimed
+// job.
+func (c *Collection) Reclaim(staleAfter time.Duration) (int, error) {
+	// find stale workers
+	var stale []string
+	err := c.registry().Distinct(bson.M{
+		"heartbeat": bson.M{
+			"$lt": time.Now().Add(-staleAfter),
+		},
+	}, "_id", &stale)
+	if err != nil {
+		return 0, err
+	}
+
+	// check list
+	if len(stale) == 0 {
+		return 0, nil
+	}
+
+	// find owned jobs
+	var jobs []Job
+	err = c.jobs.Find(bson.M{
+		"status": StatusDequeued,
+		"worker": bson.M{
+			"$in": stale,
+		},
+	}, FindOptions{
+		Select: bson.M{
+			"name":        1,
+			"worker":      1,
+			"attempts":    1,
+			"maxattempts": 1,
+			"logged":      1,
+			"refs":        1,
+		},
+	}, &jobs)
+	if err != nil {
+		return 0, err
+	}
+
+	// fail owned jobs
+	reclaimed := 0
+	for _, job := range jobs {
+		// prepare update
+		_, update, err := c.failAttempt(job, orphanedError, 0)
+		if err != nil {
+			return reclaimed, err
+		}
+
+		// fail job if still owned
+		err = c.jobs.Update(bson.M{
+			"_id":    job.ID,
+			"status": StatusDequeued,
+			"worker": job.Worker,
+		}, c.endAttempt(update))
+		if err == mgo.ErrNotFound {
+			continue
+		} else if err != nil {
+			return reclaimed, err
+		}
+
+		// update logs and payloads
+		err = c.endRelated([]Job{job}, nil, update["$set"].(bson.M)["ended"].(time.Time))
+		if err != nil {
+			return reclaimed, err
+		}
+
+		// notify observers
+		c.notify(Event{
+			Type:    EventReclaimed,
+			Job:     job.ID,
+			Name:    job.Name,
+			Attempt: job.Attempts,
+		})
+
+		reclaimed++
+	}
+
+	// remove stale workers
+	_, err = c.registry().RemoveAll(bson.M{
+		"_id": bson.M{
+			"$in": stale,
+		},
+		"heartbeat": bson.M{
+			"$lt": time.Now().Add(-staleAfter),
+		},
+	})
+	if err != nil {
+		return reclaimed, err
+	}
+
+	return reclaimed, nil
+}
+
+func (c *Collection) registry() Table {
+	return c.store.Table("workers")
+}
@@ -0,0 +1,84 @@
+package mgojq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionRegistry(t *testing.T) {
+	jqc := newCollection("test-coll-registry")
+
+	err := jqc.Heartbeat(Registration{
+		ID:       "w1",
+		Names:    []string{"foo"},
+		Capacity: 2,
+	})
+	assert.NoError(t, err)
+
+	err = jqc.Heartbeat(Registration{
+		ID:       "w2",
+		Names:    []string{"bar"},
+		Capacity: 1,
+	})
+	assert.NoError(t, err)
+
+	list, err := jqc.Workers()
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, "w1", list[0].ID)
+	assert.Equal(t, []string{"foo"}, list[0].Names)
+	assert.Equal(t, 2, list[0].Capacity)
+	assert.False(t, list[0].Registered.IsZero())
+	assert.False(t, list[0].Heartbeat.IsZero())
+
+	err = jqc.Unregister("w2")
+	assert.NoError(t, err)
+
+	err = jqc.Unregister("w2")
+	assert.NoError(t, err)
+
+	list, err = jqc.Workers()
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+}
+
+func TestCollectionReclaim(t *testing.T) {
+	jqc := newCollection("test-coll-reclaim")
+
+	id, err := jqc.Enqueue("foo", nil, 0)
+	assert.NoError(t, err)
+
+	err = jqc.Heartbeat(Registration{ID: "w1"})
+	assert.NoError(t, err)
+
+	job, err := jqc.DequeueAs("w1", []string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+
+	n, err := jqc.Reclaim(100 * time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	time.Sleep(120 * time.Millisecond)
+
+	n, err = jqc.Reclaim(100 * time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	list, err := jqc.Workers()
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+
+	job, err = jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusFailed, job.Status)
+	assert.Equal(t, "orphaned", job.Error)
+	assert.Empty(t, job.Worker)
+
+	job, err = jqc.DequeueAs("w2", []string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, id, job.ID)
+	assert.Equal(t, 2, job.Attempts)
+}
@@ -0,0 +1,173 @@
+package mgojq
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// Stats contains statistics about the jobs with a specific name.
+type Stats struct {
+	// The number of jobs per status.
+	Counts map[string]int
+
+	// The number of enqueued and failed jobs that are delayed.
+	Delayed int
+
+	// The duration the oldest due job has been waiting to be dequeued.
+	OldestPending time.Duration
+
+	// The average runtime of the jobs completed within the window.
+	AverageRuntime time.Duration
+}
+
+// Stats will return statistics for the specified job names. The average
+// runtime is calculated over the jobs that have been completed within the
+// specified window. Statistics are only available with a MongoStore, other
+// stores return ErrUnsupported.
+func (c *Collection) Stats(names []string, window time.Duration) (map[string]*Stats, error) {
+	// check store
+	if c.coll == nil {
+		return nil, ErrUnsupported
+	}
+
+	// get time
+	now := time.Now()
+
+	// prepare stats
+	stats := make(map[string]*Stats, len(names))
+	for _, name := range names {
+		stats[name] = &Stats{
+			Counts: make(map[string]int),
+		}
+	}
+
+	// count jobs per status
+	var counts []struct {
+		ID struct {
+			Name   string
+			Status string
+		} `bson:"_id"`
+		Count int
+	}
+	err := c.coll.Pipe([]bson.M{
+		{
+			"$match": bson.M{
+				"name": bson.M{
+					"$in": names,
+				},
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id": bson.M{
+					"name":   "$name",
+					"status": "$status",
+				},
+				"count": bson.M{
+					"$sum": 1,
+				},
+			},
+		},
+	}).All(&counts)
+	if err != nil {
+		return nil, err
+	}
+
+	// apply counts
+	for _, count := range counts {
+		stats[count.ID.Name].Counts[count.ID.Status] = count.Count
+	}
+
+	// get delayed and oldest pending jobs
+	var pending []struct {
+		Name    string `bson:"_id"`
+		Delayed int
+		Oldest  time.Time
+	}
+	err = c.coll.Pipe([]bson.M{
+		{
+			"$match": bson.M{
+				"name": bson.M{
+					"$in": names,
+				},
+				"status": bson.M{
+					"$in": []string{StatusEnqueued, StatusFailed},
+				},
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id": "$name",
+				"delayed": bson.M{
+					"$sum": bson.M{
+						"$cond": []interface{}{
+							bson.M{"$gt": []interface{}{"$delayed", now}},
+							1,
+							0,
+						},
+					},
+				},
+				"oldest": bson.M{
+					"$min": bson.M{
+						"$cond": []interface{}{
+							bson.M{"$lte": []interface{}{"$delayed", now}},
+							"$delayed",
+							nil,
+						},
+					},
+				},
+			},
+		},
+	}).All(&pending)
+	if err != nil {
+		return nil, err
+	}
+
+	// apply pending
+	for _, p := range pending {
+		stats[p.Name].Delayed = p.Delayed
+		if !p.Oldest.IsZero() {
+			stats[p.Name].OldestPending = now.Sub(p.Oldest)
+		}
+	}
+
+	// get average runtimes
+	var runtimes []struct {
+		Name    string `bson:"_id"`
+		Average float64
+	}
+	err = c.coll.Pipe([]bson.M{
+		{
+			"$match": bson.M{
+				"ended": bson.M{
+					"$gte": now.Add(-window),
+				},
+				"name": bson.M{
+					"$in": names,
+				},
+				"status": StatusCompleted,
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id": "$name",
+				"average": bson.M{
+					"$avg": bson.M{
+						"$subtract": []interface{}{"$ended", "$started"},
+					},
+				},
+			},
+		},
+	}).All(&runtimes)
+	if err != nil {
+		return nil, err
+	}
+
+	// apply runtimes
+	for _, r := range runtimes {
+		stats[r.Name].AverageRuntime = time.Duration(r.Average * float64(time.Millisecond))
+	}
+
+	return stats, nil
+}
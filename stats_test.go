@@ -0,0 +1,54 @@
+package mgojq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionStats(t *testing.T) {
+	requireDB(t)
+
+	jqc := newCollection("test-coll-stats")
+
+	for i := 0; i < 3; i++ {
+		_, err := jqc.Enqueue("foo", nil, 0)
+		assert.NoError(t, err)
+	}
+
+	_, err := jqc.Enqueue("foo", nil, time.Hour)
+	assert.NoError(t, err)
+
+	_, err = jqc.Enqueue("bar", nil, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+
+	time.Sleep(50 * time.Millisecond)
+
+	err = jqc.Complete(job.ID, nil)
+	assert.NoError(t, err)
+
+	job, err = jqc.Dequeue([]string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+
+	stats, err := jqc.Stats([]string{"foo", "baz"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+
+	foo := stats["foo"]
+	assert.Equal(t, map[string]int{
+		StatusEnqueued:  2,
+		StatusDequeued:  1,
+		StatusCompleted: 1,
+	}, foo.Counts)
+	assert.Equal(t, 1, foo.Delayed)
+	assert.True(t, foo.OldestPending >= 50*time.Millisecond)
+	assert.True(t, foo.AverageRuntime >= 50*time.Millisecond)
+
+	assert.Equal(t, &Stats{
+		Counts: map[string]int{},
+	}, stats["baz"])
+}
@@ -0,0 +1,202 @@
+package mgojq
+
+import (
+	"errors"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// ErrUnsupported is returned by operations that are not supported by the store
+// of the collection.
+var ErrUnsupported = errors.New("operation not supported by store")
+
+// A Store provides the tables that hold the jobs and the companion documents
+// of a collection. Tables are queried and modified using the MongoDB query and
+// update language.
+type Store interface {
+	// Table returns the table with the specified name. Jobs are stored in the
+	// table with the empty name. Pauses, rate limits, logs and workers are
+	// stored in the "control", "limits", "logs" and "workers" tables.
+	Table(name string) Table
+}
+
+// A Table is a set of documents. Methods that operate on a single document
+// return mgo.ErrNotFound if no document matches the query.
+type Table interface {
+	// Insert will insert the specified documents.
+	Insert(docs ...interface{}) error
+
+	// Find will load all matching documents into the slice pointed to by
+	// result.
+	Find(query bson.M, opts FindOptions, result interface{}) error
+
+	// FindOne will load the first matching document into result.
+	FindOne(query bson.M, opts FindOptions, result interface{}) error
+
+	// Modify will apply the update to the first matching document and load
+	// the document as it was before the update into result.
+	Modify(query bson.M, opts FindOptions, update bson.M, result interface{}) error
+
+	// Update will apply the update to the first matching document.
+	Update(query, update bson.M) error
+
+	// Upsert will apply the update to the first matching document or insert
+	// a new document made from the query and update.
+	Upsert(query, update bson.M) error
+
+	// UpdateAll will apply the update to all matching documents and return
+	// the number of updated documents.
+	UpdateAll(query, update bson.M) (int, error)
+
+	// RemoveAll will remove all matching documents and return the number of
+	// removed documents.
+	RemoveAll(query bson.M) (int, error)
+
+	// Distinct will load the distinct values of the specified key of all
+	// matching documents into the slice pointed to by result.
+	Distinct(query bson.M, key string, result interface{}) error
+
+	// Bulk will run the specified operations in any order. All operations are
+	// run even if some of them fail.
+	Bulk(ops []Operation) error
+}
+
+// FindOptions configure the documents that are loaded by Find, FindOne and
+// Modify.
+type FindOptions struct {
+	// The fields to sort by. Fields prefixed with "-" are sorted descending.
+	Sort []string
+
+	// The projection of the loaded documents.
+	Select bson.M
+
+	// The maximum number of loaded documents.
+	Limit int
+}
+
+// An Operation is an insert or update that is part of a bulk operation.
+type Operation struct {
+	// The document to insert.
+	Insert interface{}
+
+	// The query and update of the first document to update.
+	Query  bson.M
+	Update bson.M
+}
+
+// MongoStore is a store that keeps the jobs in a MongoDB collection and the
+// companion documents in the collections "<collection>.<table>".
+type MongoStore struct {
+	coll *mgo.Collection
+}
+
+// NewMongoStore will create and return a new store for the specified
+// collection.
+func NewMongoStore(coll *mgo.Collection) *MongoStore {
+	return &MongoStore{coll: coll}
+}
+
+// Collection will return the collection that holds the jobs.
+func (s *MongoStore) Collection() *mgo.Collection {
+	return s.coll
+}
+
+// Table implements the Store interface.
+func (s *MongoStore) Table(name string) Table {
+	// check name
+	if name == "" {
+		return &mongoTable{coll: s.coll}
+	}
+
+	return &mongoTable{coll: s.coll.Database.C(s.coll.Name + "." + name)}
+}
+
+type mongoTable struct {
+	coll *mgo.Collection
+}
+
+func (t *mongoTable) Insert(docs ...interface{}) error {
+	return t.coll.Insert(docs...)
+}
+
+func (t *mongoTable) Find(query bson.M, opts FindOptions, result interface{}) error {
+	return t.query(query, opts).All(result)
+}
+
+func (t *mongoTable) FindOne(query bson.M, opts FindOptions, result interface{}) error {
+	return t.query(query, opts).One(result)
+}
+
+func (t *mongoTable) Modify(query bson.M, opts FindOptions, update bson.M, result interface{}) error {
+	_, err := t.query(query, opts).Apply(mgo.Change{
+		Update: update,
+	}, result)
+	return err
+}
+
+func (t *mongoTable) Update(query, update bson.M) error {
+	return t.coll.Update(query, update)
+}
+
+func (t *mongoTable) Upsert(query, update bson.M) error {
+	_, err := t.coll.Upsert(query, update)
+	return err
+}
+
+func (t *mongoTable) UpdateAll(query, update bson.M) (int, error) {
+	info, err := t.coll.UpdateAll(query, update)
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Updated, nil
+}
+
+func (t *mongoTable) RemoveAll(query bson.M) (int, error) {
+	info, err := t.coll.RemoveAll(query)
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Removed, nil
+}
+
+func (t *mongoTable) Distinct(query bson.M, key string, result interface{}) error {
+	return t.coll.Find(query).Distinct(key, result)
+}
+
+func (t *mongoTable) Bulk(ops []Operation) error {
+	// prepare bulk
+	bulk := t.coll.Bulk()
+	bulk.Unordered()
+
+	// add operations
+	for _, op := range ops {
+		if op.Insert != nil {
+			bulk.Insert(op.Insert)
+		} else {
+			bulk.Update(op.Query, op.Update)
+		}
+	}
+
+	// run bulk
+	_, err := bulk.Run()
+	return err
+}
+
+func (t *mongoTable) query(query bson.M, opts FindOptions) *mgo.Query {
+	// prepare query
+	q := t.coll.Find(query)
+	if len(opts.Sort) > 0 {
+		q = q.Sort(opts.Sort...)
+	}
+	if opts.Select != nil {
+		q = q.Select(opts.Select)
+	}
+	if opts.Limit > 0 {
+		q = q.Limit(opts.Limit)
+	}
+
+	return q
+}
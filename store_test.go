@@ -0,0 +1,121 @@
+package mgojq
+
+import (
+	"testing"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStore(t *testing.T, store Store) {
+	table := store.Table("")
+
+	err := table.Insert(bson.M{"_id": 1, "name": "foo", "tags": []string{"a", "b"}})
+	assert.NoError(t, err)
+
+	err = table.Insert(bson.M{"_id": 2, "name": "bar", "tags": []string{"b", "c"}})
+	assert.NoError(t, err)
+
+	err = table.Insert(bson.M{"_id": 1})
+	assert.True(t, mgo.IsDup(err))
+
+	var list []bson.M
+	err = table.Find(bson.M{"tags": "b"}, FindOptions{
+		Sort:   []string{"-_id"},
+		Select: bson.M{"name": 1},
+	}, &list)
+	assert.NoError(t, err)
+	assert.Equal(t, []bson.M{
+		{"_id": 2, "name": "bar"},
+		{"_id": 1, "name": "foo"},
+	}, list)
+
+	var doc bson.M
+	err = table.FindOne(bson.M{"name": "baz"}, FindOptions{}, &doc)
+	assert.Equal(t, mgo.ErrNotFound, err)
+
+	doc = nil
+	err = table.Modify(bson.M{"name": "foo"}, FindOptions{}, bson.M{
+		"$set": bson.M{"name": "baz"},
+		"$inc": bson.M{"count": 1},
+	}, &doc)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"_id": 1, "name": "foo", "tags": []interface{}{"a", "b"}}, doc)
+
+	doc = nil
+	err = table.FindOne(bson.M{"_id": 1}, FindOptions{}, &doc)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"_id": 1, "name": "baz", "count": 1, "tags": []interface{}{"a", "b"}}, doc)
+
+	err = table.Update(bson.M{"_id": 3}, bson.M{"$set": bson.M{"name": "qux"}})
+	assert.Equal(t, mgo.ErrNotFound, err)
+
+	err = table.Upsert(bson.M{"_id": 3}, bson.M{
+		"$set":         bson.M{"name": "qux"},
+		"$setOnInsert": bson.M{"count": 0},
+	})
+	assert.NoError(t, err)
+
+	err = table.Upsert(bson.M{"_id": 3}, bson.M{
+		"$addToSet":    bson.M{"tags": "d"},
+		"$setOnInsert": bson.M{"count": 5},
+	})
+	assert.NoError(t, err)
+
+	doc = nil
+	err = table.FindOne(bson.M{"_id": 3}, FindOptions{}, &doc)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"_id": 3, "name": "qux", "count": 0, "tags": []interface{}{"d"}}, doc)
+
+	var tags []string
+	err = table.Distinct(nil, "tags", &tags)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, tags)
+
+	n, err := table.UpdateAll(bson.M{"count": bson.M{"$exists": true}}, bson.M{
+		"$pull": bson.M{"tags": "a"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	err = table.Bulk([]Operation{
+		{Insert: bson.M{"_id": 4, "name": "quux"}},
+		{Query: bson.M{"_id": 2}, Update: bson.M{"$set": bson.M{"count": 2}}},
+	})
+	assert.NoError(t, err)
+
+	list = nil
+	err = table.Find(nil, FindOptions{
+		Sort:   []string{"_id"},
+		Select: bson.M{"tags": 0},
+		Limit:  3,
+	}, &list)
+	assert.NoError(t, err)
+	assert.Equal(t, []bson.M{
+		{"_id": 1, "name": "baz", "count": 1},
+		{"_id": 2, "name": "bar", "count": 2},
+		{"_id": 3, "name": "qux", "count": 0},
+	}, list)
+
+	n, err = table.RemoveAll(bson.M{"_id": bson.M{"$gte": 3}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	n, err = count(table)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	n, err = count(store.Table("control"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestMongoStore(t *testing.T) {
+	requireDB(t)
+
+	store := NewMongoStore(db.C("test-mongo-store"))
+	assert.Equal(t, "test-mongo-store", store.Collection().Name)
+
+	testStore(t, store)
+}
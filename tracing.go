@@ -0,0 +1,92 @@
+package mgojq
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The name of the tracer used to create spans. Spans are created using the
+// global tracer provider and trace contexts are propagated using the global
+// text map propagator.
+const tracerName = "github.com/256dpi/mgojq"
+
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+func inject(ctx context.Context) map[string]string {
+	// check context
+	if ctx == nil {
+		return nil
+	}
+
+	// inject context
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+
+	return carrier
+}
+
+func extract(carrier map[string]string) trace.SpanContext {
+	// check carrier
+	if len(carrier) == 0 {
+		return trace.SpanContext{}
+	}
+
+	// extract context
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(carrier))
+
+	return trace.SpanContextFromContext(ctx)
+}
+
+func links(carrier map[string]string) []trace.Link {
+	// get span context
+	sc := extract(carrier)
+	if !sc.IsValid() {
+		return nil
+	}
+
+	return []trace.Link{{SpanContext: sc}}
+}
+
+func jobAttributes(job *Job) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("mgojq.job.id", job.ID.Hex()),
+		attribute.String("mgojq.job.name", job.Name),
+		attribute.Int("mgojq.job.attempt", job.Attempts),
+	}
+}
+
+func endSpan(span trace.Span, err error) {
+	// record error
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+func (c *Collection) track(job *Job) func() {
+	c.contexts.Store(job.ID, job.Context())
+	return func() {
+		c.contexts.Delete(job.ID)
+	}
+}
+
+func (c *Collection) tracked(id interface{}) context.Context {
+	// get context
+	if ctx, ok := c.contexts.Load(id); ok {
+		return ctx.(context.Context)
+	}
+
+	return context.Background()
+}
@@ -0,0 +1,89 @@
+package mgojq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	jqc := newCollection("test-tracing")
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+
+	id, err := jqc.EnqueueWith("foo", nil, Options{
+		Context: ctx,
+	})
+	assert.NoError(t, err)
+
+	parent.End()
+
+	job, err := jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, job.Trace["traceparent"])
+
+	var traceID trace.TraceID
+
+	pool := NewPool(1, 0, time.Hour)
+	pool.Register("foo", func(c *Collection, j *Job, quit <-chan struct{}) error {
+		traceID = trace.SpanContextFromContext(j.Context()).TraceID()
+		return c.Complete(j.ID, nil)
+	})
+
+	pool.Start(jqc)
+	time.Sleep(20 * time.Millisecond)
+	pool.Close()
+	assert.NoError(t, pool.Wait())
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		if span.Name() == "mgojq.dequeue" && len(span.Links()) == 0 {
+			continue
+		}
+		spans[span.Name()] = span
+	}
+
+	enqueue := spans["mgojq.enqueue"]
+	assert.Equal(t, parent.SpanContext().TraceID(), enqueue.SpanContext().TraceID())
+	assert.Equal(t, parent.SpanContext().SpanID(), enqueue.Parent().SpanID())
+
+	dequeue := spans["mgojq.dequeue"]
+	assert.Len(t, dequeue.Links(), 1)
+	assert.Equal(t, enqueue.SpanContext().SpanID(), dequeue.Links()[0].SpanContext.SpanID())
+
+	process := spans["mgojq.process"]
+	assert.Equal(t, traceID, process.SpanContext().TraceID())
+	assert.Len(t, process.Links(), 1)
+	assert.Equal(t, enqueue.SpanContext().SpanID(), process.Links()[0].SpanContext.SpanID())
+
+	complete := spans["mgojq.completed"]
+	assert.Equal(t, process.SpanContext().SpanID(), complete.Parent().SpanID())
+}
+
+func TestTracingDequeueEmpty(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	jqc := newCollection("test-tracing-dequeue-empty")
+
+	job, err := jqc.DequeueAs("w1", []string{"foo"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "mgojq.dequeue", spans[0].Name())
+	assert.Empty(t, spans[0].Links())
+}
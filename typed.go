@@ -0,0 +1,315 @@
+package mgojq
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// TypedWorker is a function that processes a job with decoded params of type T
+// and returns a result of type R. Unlike a Worker, the function must not
+// complete or fail the job on its own. A returned error fails the job.
+type TypedWorker[T, R any] func(c *Collection, j *Job, params T, quit <-chan struct{}) (R, error)
+
+// EnqueueTyped will enqueue a job using the specified name and params that are
+// encoded to a document using the bson package.
+func EnqueueTyped[T any](c *Collection, name string, params T, delay time.Duration) (bson.ObjectId, error) {
+	return EnqueueTypedWith(c, name, params, Options{Delay: delay})
+}
+
+// EnqueueTypedWith will enqueue a job like EnqueueTyped using the specified
+// options.
+func EnqueueTypedWith[T any](c *Collection, name string, params T, opts Options) (bson.ObjectId, error) {
+	// encode params
+	doc, err := encode(params)
+	if err != nil {
+		return "", err
+	}
+
+	return c.EnqueueWith(name, doc, opts)
+}
+
+// A Validator is implemented by typed params that check their own contents
+// after they have been decoded.
+type Validator interface {
+	Validate() error
+}
+
+// RegisterTyped will register the specified typed worker for the specified job
+// name. The params of dequeued jobs are decoded into a value of type T. If T
+// implements the Validator interface the decoded params are validated. If the
+// params cannot be decoded or validated the job is cancelled with the error
+// message as the reason. If the worker returns an error the job is failed with
+// the error message. Otherwise, the job is completed with the encoded result of
+// the worker.
+func RegisterTyped[T, R any](p *Pool, name string, worker TypedWorker[T, R], middleware ...Middleware) {
+	p.Register(name, func(c *Collection, j *Job, quit <-chan struct{}) error {
+		// decode params
+		params, err := DecodeParams[T](j)
+		if err != nil {
+			return c.Cancel(j.ID, "invalid params: "+err.Error())
+		}
+
+		// call worker
+		result, err := worker(c, j, params, quit)
+		if err != nil {
+			return c.Fail(j.ID, err.Error(), 0)
+		}
+
+		// encode result
+		doc, err := encode(result)
+		if err != nil {
+			return c.Fail(j.ID, "invalid result: "+err.Error(), 0)
+		}
+
+		return c.Complete(j.ID, doc)
+	}, middleware...)
+}
+
+// DecodeParams will decode the params of the specified job into a value of
+// type T. Unlike with the bson package, values that do not match the type of
+// their field return an error instead of leaving the field empty. Fields that
+// are unknown to T are ignored. If T implements the Validator interface the
+// decoded params are validated.
+func DecodeParams[T any](j *Job) (T, error) {
+	// decode params
+	var params T
+	err := decode(j.Params, &params)
+	if err != nil {
+		return params, err
+	}
+
+	// check params
+	err = check(j.Params, params)
+	if err != nil {
+		return params, err
+	}
+
+	// validate params
+	if v, ok := any(&params).(Validator); ok {
+		err = v.Validate()
+	} else if v, ok := any(params).(Validator); ok {
+		err = v.Validate()
+	}
+
+	return params, err
+}
+
+// DecodeResult will decode the result of the specified job into a value of
+// type R. Values that do not match the type of their field return an error.
+func DecodeResult[R any](j *Job) (R, error) {
+	// decode result
+	var result R
+	err := decode(j.Result, &result)
+	if err != nil {
+		return result, err
+	}
+
+	// check result
+	err = check(j.Result, result)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func encode(v interface{}) (bson.M, error) {
+	// marshal value
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	// unmarshal document
+	var doc bson.M
+	err = bson.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+func decode(doc bson.M, v interface{}) error {
+	// marshal document
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	return bson.Unmarshal(data, v)
+}
+
+func check(doc bson.M, v interface{}) error {
+	// encode decoded value
+	out, err := encode(v)
+	if err != nil {
+		return err
+	}
+
+	// compare documents
+	path := mismatch(doc, out, reflect.TypeOf(v))
+	if path != "" {
+		return fmt.Errorf("invalid value for field %q", path)
+	}
+
+	return nil
+}
+
+var setterType = reflect.TypeOf((*bson.Setter)(nil)).Elem()
+
+func mismatch(in, out interface{}, typ reflect.Type) string {
+	// get element type
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	// skip interfaces and custom decoders
+	if typ == nil || typ.Kind() == reflect.Interface || reflect.PtrTo(typ).Implements(setterType) {
+		return ""
+	}
+
+	switch in := in.(type) {
+	case bson.M:
+		// check document
+		doc, ok := out.(bson.M)
+		if !ok {
+			return "."
+		}
+
+		// get known fields
+		var fields map[string]reflect.Type
+		if typ.Kind() == reflect.Struct {
+			fields = fieldTypes(typ)
+		} else if typ.Kind() != reflect.Map {
+			return "."
+		}
+
+		// check fields
+		for key, value := range in {
+			// get field type, unknown fields are ignored
+			var fieldType reflect.Type
+			if fields == nil {
+				fieldType = typ.Elem()
+			} else if fieldType, ok = fields[key]; !ok {
+				continue
+			}
+
+			// check omitted value
+			res, ok := doc[key]
+			if !ok {
+				if !isZero(value) {
+					return key
+				}
+				continue
+			}
+
+			// check value
+			if path := mismatch(value, res, fieldType); path == "." {
+				return key
+			} else if path != "" {
+				return key + "." + path
+			}
+		}
+
+		return ""
+	case []interface{}:
+		// check array
+		arr, ok := out.([]interface{})
+		if !ok || len(arr) != len(in) || (typ.Kind() != reflect.Slice && typ.Kind() != reflect.Array) {
+			return "."
+		}
+
+		// check items
+		for i, value := range in {
+			if path := mismatch(value, arr[i], typ.Elem()); path == "." {
+				return fmt.Sprint(i)
+			} else if path != "" {
+				return fmt.Sprint(i) + "." + path
+			}
+		}
+
+		return ""
+	case nil:
+		return ""
+	}
+
+	// check value
+	if out == nil && isZero(in) {
+		return ""
+	} else if compareValues(in, out) != 0 {
+		return "."
+	}
+
+	return ""
+}
+
+func fieldTypes(typ reflect.Type) map[string]reflect.Type {
+	// collect fields like the bson package
+	fields := map[string]reflect.Type{}
+	for i := 0; i < typ.NumField(); i++ {
+		// get field
+		field := typ.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		// get tag
+		tag := field.Tag.Get("bson")
+		if tag == "" && !strings.Contains(string(field.Tag), ":") {
+			tag = string(field.Tag)
+		}
+		if tag == "-" {
+			continue
+		}
+
+		// get name and flags
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		inline := false
+		for _, flag := range parts[1:] {
+			if flag == "inline" {
+				inline = true
+			}
+		}
+
+		// merge inlined structs
+		if inline && field.Type.Kind() == reflect.Struct {
+			for key, value := range fieldTypes(field.Type) {
+				fields[key] = value
+			}
+			continue
+		} else if inline {
+			continue
+		}
+
+		// add field
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+
+	return fields
+}
+
+func isZero(value interface{}) bool {
+	// check value
+	if value == nil {
+		return true
+	}
+
+	// check documents and arrays
+	switch value := value.(type) {
+	case bson.M:
+		return len(value) == 0
+	case []interface{}:
+		return len(value) == 0
+	}
+
+	return reflect.ValueOf(value).IsZero()
+}
@@ -0,0 +1,163 @@
+package mgojq
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+)
+
+type adderParams struct {
+	A int `bson:"a"`
+	B int `bson:"b"`
+}
+
+func (p adderParams) Validate() error {
+	if p.A < 0 || p.B < 0 {
+		return errors.New("negative value")
+	}
+
+	return nil
+}
+
+type adderResult struct {
+	R int `bson:"r"`
+}
+
+func TestEnqueueTyped(t *testing.T) {
+	jqc := newCollection("test-enqueue-typed")
+
+	id, err := EnqueueTyped(jqc, "foo", adderParams{A: 1, B: 2}, 0)
+	assert.NoError(t, err)
+
+	job, err := jqc.Fetch(id)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, job.Params["a"])
+	assert.Equal(t, 2, job.Params["b"])
+
+	params, err := DecodeParams[adderParams](job)
+	assert.NoError(t, err)
+	assert.Equal(t, adderParams{A: 1, B: 2}, params)
+
+	_, err = EnqueueTyped(jqc, "foo", 42, 0)
+	assert.Error(t, err)
+}
+
+func TestRegisterTyped(t *testing.T) {
+	jqc := newCollection("test-register-typed")
+	jqc.Define("foo", JobDefinition{
+		Backoff: func(int) time.Duration {
+			return time.Hour
+		},
+	})
+
+	pool := NewPool(1, 0, time.Hour)
+	RegisterTyped(pool, "foo", func(c *Collection, j *Job, p adderParams, quit <-chan struct{}) (adderResult, error) {
+		if p.A == 0 {
+			return adderResult{}, errors.New("zero value")
+		}
+
+		return adderResult{R: p.A + p.B}, nil
+	})
+
+	pool.Start(jqc)
+
+	id1, err := EnqueueTyped(jqc, "foo", adderParams{A: 1, B: 2}, 0)
+	assert.NoError(t, err)
+
+	id2, err := EnqueueTyped(jqc, "foo", adderParams{A: 0, B: 2}, 0)
+	assert.NoError(t, err)
+
+	id3, err := EnqueueTyped(jqc, "foo", adderParams{A: -1, B: 2}, 0)
+	assert.NoError(t, err)
+
+	id4, err := jqc.Enqueue("foo", bson.M{"a": "1"}, 0)
+	assert.NoError(t, err)
+
+	time.Sleep(20 * time.Millisecond)
+	pool.Close()
+	assert.NoError(t, pool.Wait())
+
+	job, err := jqc.Fetch(id1)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCompleted, job.Status)
+
+	result, err := DecodeResult[adderResult](job)
+	assert.NoError(t, err)
+	assert.Equal(t, adderResult{R: 3}, result)
+
+	job, err = jqc.Fetch(id2)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusFailed, job.Status)
+	assert.Equal(t, "zero value", job.Error)
+
+	job, err = jqc.Fetch(id3)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCancelled, job.Status)
+	assert.Equal(t, "invalid params: negative value", job.Reason)
+
+	job, err = jqc.Fetch(id4)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCancelled, job.Status)
+	assert.Equal(t, `invalid params: invalid value for field "a"`, job.Reason)
+}
+
+type nestedParams struct {
+	Name  string         `bson:"name"`
+	Items []adderParams  `bson:"items"`
+	Inner *adderParams   `bson:"inner,omitempty"`
+	Extra map[string]int `bson:"extra"`
+	Count float64
+}
+
+func TestDecodeParamsStrict(t *testing.T) {
+	table := []struct {
+		params bson.M
+		err    string
+	}{
+		{bson.M{"a": 1, "b": 2}, ""},
+		{bson.M{"a": 1, "c": "foo"}, ""},
+		{bson.M{"a": nil}, ""},
+		{bson.M{"a": "1"}, `invalid value for field "a"`},
+		{bson.M{"a": 1.5}, `invalid value for field "a"`},
+		{bson.M{"a": bson.M{"b": 1}}, `invalid value for field "a"`},
+	}
+
+	for _, item := range table {
+		_, err := DecodeParams[adderParams](&Job{Params: item.params})
+		if item.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, item.err)
+		}
+	}
+
+	table = []struct {
+		params bson.M
+		err    string
+	}{
+		{bson.M{
+			"name":  "foo",
+			"items": []interface{}{bson.M{"a": 1}},
+			"inner": bson.M{"b": 2},
+			"extra": bson.M{"x": 1},
+			"count": 3,
+		}, ""},
+		{bson.M{"items": []interface{}{bson.M{"a": "1"}}}, `invalid value for field "items.0.a"`},
+		{bson.M{"items": bson.M{"a": 1}}, `invalid value for field "items"`},
+		{bson.M{"inner": "foo"}, `invalid value for field "inner"`},
+		{bson.M{"extra": bson.M{"x": "y"}}, `invalid value for field "extra.x"`},
+		{bson.M{"count": "3"}, `invalid value for field "count"`},
+	}
+
+	for _, item := range table {
+		_, err := DecodeParams[nestedParams](&Job{Params: item.params})
+		if item.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, item.err)
+		}
+	}
+}
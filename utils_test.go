@@ -1,6 +1,7 @@
 package mgojq
 
 import (
+	"testing"
 	"time"
 
 	"github.com/globalsign/mgo"
@@ -11,9 +12,10 @@ var db *mgo.Database
 
 func init() {
 	// create session
-	sess, err := mgo.Dial("mongodb://localhost/test-mgojq")
+	sess, err := mgo.DialWithTimeout("mongodb://localhost/test-mgojq", time.Second)
 	if err != nil {
-		panic(err)
+		// fallback to in-memory stores
+		return
 	}
 
 	// save db reference
@@ -32,6 +34,27 @@ func init() {
 	}
 }
 
+func newCollection(name string) *Collection {
+	// use an in-memory store if no database is available
+	if db == nil {
+		return New(NewMemoryStore())
+	}
+
+	return Wrap(db.C(name))
+}
+
+func requireDB(t *testing.T) {
+	if db == nil {
+		t.Skip("database not available")
+	}
+}
+
+func count(table Table) (int, error) {
+	var list []bson.M
+	err := table.Find(nil, FindOptions{}, &list)
+	return len(list), err
+}
+
 var setTime = time.Now()
 
 func replaceTimeSlice(s []bson.M) []bson.M {
@@ -48,6 +71,12 @@ func replaceTimeMap(m bson.M) bson.M {
 			replaceTimeMap(v)
 		} else if v, ok := value.([]bson.M); ok {
 			replaceTimeSlice(v)
+		} else if v, ok := value.([]interface{}); ok {
+			for _, item := range v {
+				if m, ok := item.(bson.M); ok {
+					replaceTimeMap(m)
+				}
+			}
 		} else if v, ok := value.(time.Time); ok && !v.IsZero() {
 			m[key] = setTime
 		}
@@ -73,5 +102,15 @@ func replaceTimeJob(j *Job) *Job {
 		j.Ended = setTime
 	}
 
+	for i := range j.History {
+		if !j.History[i].Started.IsZero() {
+			j.History[i].Started = setTime
+		}
+
+		if !j.History[i].Ended.IsZero() {
+			j.History[i].Ended = setTime
+		}
+	}
+
 	return j
 }
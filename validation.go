@@ -0,0 +1,398 @@
+package mgojq
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// A ValidationError is returned by Enqueue, EnqueueWith and Bulk.Run if the
+// params of a job have been rejected by the validator or schema of the job
+// definition.
+type ValidationError struct {
+	// The name of the job.
+	Name string
+
+	// The path of the invalid params field, if known.
+	Field string
+
+	// The description of the problem.
+	Message string
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	// check field
+	if e.Field == "" {
+		return fmt.Sprintf("invalid params for %s: %s", e.Name, e.Message)
+	}
+
+	return fmt.Sprintf("invalid params for %s: %s: %s", e.Name, e.Field, e.Message)
+}
+
+// A Schema describes the expected structure of params using a subset of the
+// keywords supported by the MongoDB $jsonSchema operator. The same schema is
+// checked at enqueue time and can be installed as a collection validator
+// using EnsureValidator.
+type Schema struct {
+	// The expected BSON type: "string", "int", "long", "double", "number",
+	// "bool", "object", "array", "objectId", "date" or "null".
+	BSONType string `bson:"bsonType,omitempty"`
+
+	// The fields that must be present in an object.
+	Required []string `bson:"required,omitempty"`
+
+	// The schemas of the fields of an object.
+	Properties map[string]*Schema `bson:"properties,omitempty"`
+
+	// The allowed values.
+	Enum []interface{} `bson:"enum,omitempty"`
+
+	// The inclusive bounds of a number.
+	Minimum *float64 `bson:"minimum,omitempty"`
+	Maximum *float64 `bson:"maximum,omitempty"`
+
+	// The bounds of the length of a string.
+	MinLength *int `bson:"minLength,omitempty"`
+	MaxLength *int `bson:"maxLength,omitempty"`
+
+	// The regular expression a string must match.
+	Pattern string `bson:"pattern,omitempty"`
+
+	// The schema of the items of an array.
+	Items *Schema `bson:"items,omitempty"`
+
+	// The bounds of the length of an array.
+	MinItems *int `bson:"minItems,omitempty"`
+	MaxItems *int `bson:"maxItems,omitempty"`
+}
+
+func (s *Schema) check(path string, value interface{}) (string, string) {
+	// check type
+	if s.BSONType != "" && !hasType(value, s.BSONType) {
+		return path, "must be of type " + s.BSONType
+	}
+
+	// check enum
+	if len(s.Enum) > 0 {
+		found := false
+		for _, item := range s.Enum {
+			if compareValues(item, value) == 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return path, "must be one of the allowed values"
+		}
+	}
+
+	// check number
+	if n, ok := toFloat(value); ok {
+		if s.Minimum != nil && n < *s.Minimum {
+			return path, fmt.Sprintf("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			return path, fmt.Sprintf("must be at most %v", *s.Maximum)
+		}
+	}
+
+	// check string
+	if str, ok := value.(string); ok {
+		length := utf8.RuneCountInString(str)
+		if s.MinLength != nil && length < *s.MinLength {
+			return path, fmt.Sprintf("must be at least %d characters long", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			return path, fmt.Sprintf("must be at most %d characters long", *s.MaxLength)
+		}
+		if s.Pattern != "" {
+			ok, err := regexp.MatchString(s.Pattern, str)
+			if err != nil || !ok {
+				return path, "must match " + s.Pattern
+			}
+		}
+	}
+
+	// check object
+	if obj, ok := toMap(value); ok {
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return join(path, name), "is required"
+			}
+		}
+
+		// check properties in a stable order
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if v, ok := obj[name]; ok {
+				if field, msg := s.Properties[name].check(join(path, name), v); msg != "" {
+					return field, msg
+				}
+			}
+		}
+	}
+
+	// check array
+	if items, ok := toSlice(value); ok {
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			return path, fmt.Sprintf("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			return path, fmt.Sprintf("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				if field, msg := s.Items.check(join(path, fmt.Sprint(i)), item); msg != "" {
+					return field, msg
+				}
+			}
+		}
+	}
+
+	return "", ""
+}
+
+func (c *Collection) validate(name string, params bson.M) error {
+	// get definition
+	def, ok := c.definitions[name]
+	if !ok {
+		return nil
+	}
+
+	// check schema
+	if def.Schema != nil {
+		var value interface{} = params
+		if params == nil {
+			value = bson.M{}
+		}
+		if field, msg := def.Schema.check("", value); msg != "" {
+			return &ValidationError{Name: name, Field: field, Message: msg}
+		}
+	}
+
+	// run validator
+	if def.Validate != nil {
+		err := def.Validate(params)
+		if verr, ok := err.(*ValidationError); ok {
+			verr.Name = name
+			return verr
+		} else if err != nil {
+			return &ValidationError{Name: name, Message: err.Error()}
+		}
+	}
+
+	return nil
+}
+
+// EnsureValidator will install the schemas of all job definitions as a
+// MongoDB $jsonSchema validator on the collection. Jobs with names that have
+// no schema are not validated. Existing jobs are only validated when they are
+// updated and already valid. The validator is only installed with a
+// MongoStore, other stores are left unchanged.
+//
+// Note: The database cannot look into encrypted, compressed or offloaded
+// params. Such jobs are not validated by the installed validator but only
+// when they are enqueued. Null params are accepted if the schema accepts an
+// empty document.
+func (c *Collection) EnsureValidator() error {
+	// check store
+	if c.coll == nil {
+		return nil
+	}
+
+	// collect names and schemas
+	var names []string
+	var rules []bson.M
+	for name, def := range c.definitions {
+		if def.Schema == nil {
+			continue
+		}
+
+		// skip transformed params
+		checks := []bson.M{
+			{"encrypted.params": bson.M{"$exists": true}},
+			{"compressed.params": bson.M{"$exists": true}},
+			{"refs.params": bson.M{"$exists": true}},
+		}
+
+		// allow null params if an empty document is valid
+		if _, msg := def.Schema.check("", bson.M{}); msg == "" {
+			checks = append(checks, bson.M{"params": nil})
+		}
+
+		// check params
+		checks = append(checks, bson.M{
+			"$jsonSchema": bson.M{
+				"properties": bson.M{
+					"params": withObjectType(*def.Schema),
+				},
+			},
+		})
+
+		names = append(names, name)
+		rules = append(rules, bson.M{
+			"name": name,
+			"$or":  checks,
+		})
+	}
+
+	// prepare validator
+	validator := bson.M{
+		"$or": append([]bson.M{{
+			"name": bson.M{
+				"$nin": names,
+			},
+		}}, rules...),
+	}
+
+	// update validator
+	err := c.coll.Database.Run(bson.D{
+		{Name: "collMod", Value: c.coll.Name},
+		{Name: "validator", Value: validator},
+		{Name: "validationLevel", Value: "moderate"},
+	}, nil)
+	if qerr, ok := err.(*mgo.QueryError); ok && qerr.Code == 26 {
+		// create collection if missing
+		return c.coll.Create(&mgo.CollectionInfo{
+			Validator:       validator,
+			ValidationLevel: "moderate",
+		})
+	}
+
+	return err
+}
+
+func withObjectType(schema Schema) Schema {
+	// params are always stored as an object
+	if schema.BSONType == "" {
+		schema.BSONType = "object"
+	}
+
+	return schema
+}
+
+func hasType(value interface{}, typ string) bool {
+	switch typ {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "int":
+		n, ok := toInt(value)
+		return ok && n >= math.MinInt32 && n <= math.MaxInt32
+	case "long":
+		_, ok := toInt(value)
+		return ok
+	case "double":
+		switch value.(type) {
+		case float32, float64:
+			return true
+		}
+		return false
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "bool":
+		_, ok := value.(bool)
+		return ok
+	case "object":
+		_, ok := toMap(value)
+		return ok
+	case "array":
+		_, ok := toSlice(value)
+		return ok
+	case "objectId":
+		_, ok := value.(bson.ObjectId)
+		return ok
+	case "date":
+		_, ok := value.(time.Time)
+		return ok
+	case "null":
+		return value == nil
+	}
+
+	return false
+}
+
+func toInt(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+
+	return 0, false
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	// check integers
+	if n, ok := toInt(value); ok {
+		return float64(n), true
+	}
+
+	switch v := value.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+
+	return 0, false
+}
+
+func toMap(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case bson.M:
+		return v, true
+	case map[string]interface{}:
+		return v, true
+	case bson.D:
+		return v.Map(), true
+	}
+
+	return nil, false
+}
+
+func toSlice(value interface{}) ([]interface{}, bool) {
+	// documents are not arrays
+	if _, ok := value.(bson.D); ok {
+		return nil, false
+	}
+
+	// check value
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+
+	// copy items
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+
+	return items, true
+}
+
+func join(path, name string) string {
+	return strings.TrimPrefix(path+"."+name, ".")
+}
@@ -0,0 +1,215 @@
+package mgojq
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemaCheck(t *testing.T) {
+	one := 1.0
+	two := 2
+
+	schema := &Schema{
+		BSONType: "object",
+		Required: []string{"email"},
+		Properties: map[string]*Schema{
+			"email": {
+				BSONType: "string",
+				Pattern:  "@",
+			},
+			"count": {
+				BSONType: "int",
+				Minimum:  &one,
+			},
+			"tags": {
+				BSONType: "array",
+				MaxItems: &two,
+				Items: &Schema{
+					Enum: []interface{}{"a", "b"},
+				},
+			},
+			"level": {
+				Enum: []interface{}{1, 2},
+			},
+		},
+	}
+
+	table := []struct {
+		params bson.M
+		field  string
+		msg    string
+	}{
+		{bson.M{"email": "a@b"}, "", ""},
+		{bson.M{"email": "a@b", "count": 1, "tags": []string{"a", "b"}}, "", ""},
+		{bson.M{}, "email", "is required"},
+		{bson.M{"email": 42}, "email", "must be of type string"},
+		{bson.M{"email": "ab"}, "email", "must match @"},
+		{bson.M{"email": "a@b", "count": 0}, "count", "must be at least 1"},
+		{bson.M{"email": "a@b", "count": 1.5}, "count", "must be of type int"},
+		{bson.M{"email": "a@b", "tags": []string{"a", "b", "a"}}, "tags", "must have at most 2 items"},
+		{bson.M{"email": "a@b", "tags": []interface{}{"a", "c"}}, "tags.1", "must be one of the allowed values"},
+		{bson.M{"email": "a@b", "level": int64(1)}, "", ""},
+		{bson.M{"email": "a@b", "level": 2.0}, "", ""},
+		{bson.M{"email": "a@b", "level": 3}, "level", "must be one of the allowed values"},
+		{bson.M{"email": "a@b", "level": "1"}, "level", "must be one of the allowed values"},
+	}
+
+	for _, item := range table {
+		field, msg := schema.check("", item.params)
+		assert.Equal(t, item.field, field, item.params)
+		assert.Equal(t, item.msg, msg, item.params)
+	}
+}
+
+func TestEnqueueValidation(t *testing.T) {
+	jqc := newCollection("test-enqueue-validation")
+	dbc := jqc.jobs
+
+	jqc.Define("foo", JobDefinition{
+		Schema: &Schema{
+			Required: []string{"a"},
+		},
+		Validate: func(params bson.M) error {
+			if params["a"] == 0 {
+				return errors.New("zero value")
+			}
+
+			return nil
+		},
+	})
+
+	_, err := jqc.Enqueue("foo", nil, 0)
+	assert.Equal(t, &ValidationError{
+		Name:    "foo",
+		Field:   "a",
+		Message: "is required",
+	}, err)
+	assert.Equal(t, "invalid params for foo: a: is required", err.Error())
+
+	_, err = jqc.Enqueue("foo", bson.M{"a": 0}, 0)
+	assert.Equal(t, &ValidationError{
+		Name:    "foo",
+		Message: "zero value",
+	}, err)
+
+	_, err = jqc.Enqueue("foo", bson.M{"a": 1}, 0)
+	assert.NoError(t, err)
+
+	n, err := count(dbc)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestBulkValidation(t *testing.T) {
+	jqc := newCollection("test-bulk-validation")
+	dbc := jqc.jobs
+
+	jqc.Define("foo", JobDefinition{
+		Schema: &Schema{
+			Required: []string{"a"},
+		},
+	})
+
+	bulk := jqc.Bulk()
+	bulk.Enqueue("foo", bson.M{"a": 1}, 0)
+	bulk.Enqueue("foo", nil, 0)
+
+	err := bulk.Run()
+	assert.IsType(t, &ValidationError{}, err)
+
+	n, err := count(dbc)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestEnsureValidator(t *testing.T) {
+	requireDB(t)
+
+	jqc := newCollection("test-ensure-validator")
+	dbc := jqc.jobs
+
+	jqc.Define("foo", JobDefinition{
+		Schema: &Schema{
+			Required: []string{"a"},
+			Properties: map[string]*Schema{
+				"a": {BSONType: "string"},
+			},
+		},
+	})
+
+	err := jqc.EnsureValidator()
+	assert.NoError(t, err)
+
+	err = jqc.EnsureValidator()
+	assert.NoError(t, err)
+
+	err = dbc.Insert(bson.M{"name": "foo", "params": bson.M{"a": 1}})
+	assert.Error(t, err)
+
+	err = dbc.Insert(bson.M{"name": "foo", "params": bson.M{"a": "b"}})
+	assert.NoError(t, err)
+
+	err = dbc.Insert(bson.M{"name": "bar", "params": bson.M{"a": 1}})
+	assert.NoError(t, err)
+
+	err = dbc.Insert(bson.M{"name": "foo", "params": nil})
+	assert.Error(t, err)
+
+	err = dbc.Insert(bson.M{"name": "foo", "params": nil, "encrypted": bson.M{"params": "x"}})
+	assert.NoError(t, err)
+}
+
+func TestEnsureValidatorTransformedParams(t *testing.T) {
+	requireDB(t)
+
+	jqc := newCollection("test-ensure-validator-transformed-params")
+
+	jqc.Define("foo", JobDefinition{
+		Schema: &Schema{
+			Required: []string{"a"},
+			Properties: map[string]*Schema{
+				"a": {BSONType: "string"},
+			},
+		},
+	})
+	jqc.Define("bar", JobDefinition{
+		Schema: &Schema{
+			Properties: map[string]*Schema{
+				"a": {BSONType: "string"},
+			},
+		},
+	})
+
+	err := jqc.EnsureValidator()
+	assert.NoError(t, err)
+
+	_, err = jqc.Enqueue("bar", nil, 0)
+	assert.NoError(t, err)
+
+	err = jqc.jobs.Insert(bson.M{"name": "bar", "params": nil})
+	assert.NoError(t, err)
+
+	jqc.SetCompression(CodecGzip, 1)
+
+	_, err = jqc.Enqueue("foo", bson.M{"a": "b"}, 0)
+	assert.NoError(t, err)
+
+	jqc.SetOffload(1)
+
+	_, err = jqc.Enqueue("foo", bson.M{"a": "b"}, 0)
+	assert.NoError(t, err)
+
+	jqc.SetEncryption(&Keyring{
+		Current: "k1",
+		Keys: map[string][]byte{
+			"k1": bytes.Repeat([]byte{1}, 32),
+		},
+	})
+
+	_, err = jqc.Enqueue("foo", bson.M{"a": "b"}, 0)
+	assert.NoError(t, err)
+}